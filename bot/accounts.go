@@ -0,0 +1,246 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/forge"
+	"github.com/zinstack625/mostful_manager/utils"
+)
+
+// linkGitHub ties a student to their GitHub account, so that nobody turns in
+// someone else's pull request:
+//
+//	/linkgithub                  - show the linked account
+//	/linkgithub octocat          - get a code to put into the profile bio or a gist
+//	/linkgithub verify           - check the code is there
+//	/linkgithub off              - unlink
+//
+// Admins link students without a code with /linkgithub @student octocat,
+// let anyone's pull requests through with /linkgithub @student * and unlink
+// with /linkgithub @student off.
+func (b *Bot) linkGitHub(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", "application/json")
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to parse form"))
+		log.Println("Something went wrong with parsing the url: ", err.Error())
+		return
+	}
+	if req.Form.Get("token") != b.cfg.LinkGitHub {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong token secret"))
+		return
+	}
+	if b.pulls == nil {
+		utils.RespondEphemeral(resp, "GitHub isn't asked about pull requests here, there's nothing to link")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	args := strings.Fields(req.Form.Get("text"))
+	if len(args) > 0 && strings.HasPrefix(args[0], "@") {
+		b.linkGitHubFor(ctx, resp, req, args)
+		return
+	}
+	mmstID := req.Form.Get("user_id")
+	acc, err := b.store.GetForgeAccount(ctx, mmstID, string(forge.GitHub))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Something went wrong at linking, db.GetForgeAccount: %s", err)
+		utils.RespondEphemeral(resp, "Unable to find your account, try again later")
+		return
+	}
+	switch {
+	case len(args) == 0:
+		utils.RespondEphemeral(resp, describeAccount(acc))
+	case len(args) == 1 && args[0] == "off":
+		if acc == nil {
+			utils.RespondEphemeral(resp, "You have no GitHub account linked")
+			return
+		}
+		if err := b.store.RemoveForgeAccount(ctx, acc); err != nil {
+			log.Printf("Something went wrong at unlinking, db.RemoveForgeAccount: %s", err)
+			utils.RespondEphemeral(resp, "Unable to save, try again later")
+			return
+		}
+		utils.RespondEphemeral(resp, fmt.Sprintf("Unlinked %s", acc.Login))
+	case len(args) == 1 && args[0] == "verify":
+		if acc == nil {
+			utils.RespondEphemeral(resp, "Tell me your GitHub login first, /linkgithub octocat")
+			return
+		}
+		if acc.Verified {
+			utils.RespondEphemeral(resp, describeAccount(acc))
+			return
+		}
+		ok, err := b.pulls.ProfileHas(ctx, acc.Login, acc.Code)
+		if errors.Is(err, forge.ErrNoAccount) {
+			utils.RespondEphemeral(resp, fmt.Sprintf("There's no %s on GitHub", acc.Login))
+			return
+		}
+		if err != nil {
+			log.Printf("Unable to check GitHub profile of %s: %s", acc.Login, err)
+			utils.RespondEphemeral(resp, "Unable to reach GitHub, try again later")
+			return
+		}
+		if !ok {
+			utils.RespondEphemeral(resp, fmt.Sprintf("Can't find `%s` in the bio or public gists of %s. GitHub may take a minute to show it", acc.Code, acc.Login))
+			return
+		}
+		acc.Verified, acc.Code = true, ""
+		if err := b.store.SetForgeAccount(ctx, acc); err != nil {
+			log.Printf("Something went wrong at verifying, db.SetForgeAccount: %s", err)
+			utils.RespondEphemeral(resp, "Unable to save, try again later")
+			return
+		}
+		utils.RespondEphemeral(resp, fmt.Sprintf("Linked to %s, you may remove the code now", acc.Login))
+	case len(args) == 1:
+		if acc != nil && acc.Verified && strings.EqualFold(acc.Login, args[0]) {
+			utils.RespondEphemeral(resp, describeAccount(acc))
+			return
+		}
+		code, err := verificationCode()
+		if err != nil {
+			log.Printf("Unable to make a verification code: %s", err)
+			utils.RespondEphemeral(resp, "Something went wrong, try again later")
+			return
+		}
+		acc = &database.ForgeAccount{
+			MmstID: mmstID,
+			Forge:  string(forge.GitHub),
+			Login:  args[0],
+			Code:   code,
+		}
+		if err := b.store.SetForgeAccount(ctx, acc); err != nil {
+			log.Printf("Something went wrong at linking, db.SetForgeAccount: %s", err)
+			utils.RespondEphemeral(resp, "Unable to save, try again later")
+			return
+		}
+		utils.RespondEphemeral(resp, fmt.Sprintf("Put `%s` into the bio of %s, or into the description of a public gist, then run /linkgithub verify", code, acc.Login))
+	default:
+		utils.RespondEphemeral(resp, "Usage: /linkgithub [login|verify|off]")
+	}
+}
+
+// linkGitHubFor is the admin side of /linkgithub, args start with the student
+func (b *Bot) linkGitHubFor(ctx context.Context, resp http.ResponseWriter, req *http.Request, args []string) {
+	if ok, err := b.store.CheckAdmin(ctx, &database.Admin{
+		MmstID: req.Form.Get("user_id"),
+		Tag:    req.Form.Get("user_name"),
+	}); err != nil || !ok {
+		utils.RespondEphemeral(resp, "You have no permission!")
+		return
+	}
+	if len(args) > 2 {
+		utils.RespondEphemeral(resp, "Usage: /linkgithub @student [login|*|off]")
+		return
+	}
+	tag := strings.TrimPrefix(args[0], "@")
+	user, err := b.client.GetUserByUsername(ctx, tag)
+	if err != nil {
+		utils.RespondEphemeral(resp, "No such user!")
+		return
+	}
+	acc, err := b.store.GetForgeAccount(ctx, user.Id, string(forge.GitHub))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Something went wrong at linking, db.GetForgeAccount: %s", err)
+		utils.RespondEphemeral(resp, "Unable to find the account, try again later")
+		return
+	}
+	if len(args) == 1 {
+		utils.RespondEphemeral(resp, fmt.Sprintf("@%s: %s", tag, describeAccount(acc)))
+		return
+	}
+	if args[1] == "off" {
+		if acc != nil {
+			if err := b.store.RemoveForgeAccount(ctx, acc); err != nil {
+				log.Printf("Something went wrong at unlinking, db.RemoveForgeAccount: %s", err)
+				utils.RespondEphemeral(resp, "Unable to save, try again later")
+				return
+			}
+		}
+		utils.RespondEphemeral(resp, fmt.Sprintf("@%s has no GitHub account linked now", tag))
+		return
+	}
+	if acc == nil {
+		acc = &database.ForgeAccount{MmstID: user.Id, Forge: string(forge.GitHub)}
+	}
+	if args[1] == "*" {
+		acc.AnyAuthor = true
+	} else {
+		acc.Login, acc.Code, acc.Verified, acc.AnyAuthor = args[1], "", true, false
+	}
+	if err := b.store.SetForgeAccount(ctx, acc); err != nil {
+		log.Printf("Something went wrong at linking, db.SetForgeAccount: %s", err)
+		utils.RespondEphemeral(resp, "Unable to save, try again later")
+		return
+	}
+	utils.RespondEphemeral(resp, fmt.Sprintf("@%s: %s", tag, describeAccount(acc)))
+}
+
+func describeAccount(acc *database.ForgeAccount) string {
+	switch {
+	case acc == nil:
+		return "No GitHub account linked"
+	case acc.AnyAuthor:
+		return "Pull requests by anyone are taken"
+	case acc.Verified:
+		return fmt.Sprintf("Linked to %s", acc.Login)
+	default:
+		return fmt.Sprintf("Waiting for `%s` to show up on %s, run /linkgithub verify once it's there", acc.Code, acc.Login)
+	}
+}
+
+// verificationCode is a random code for a student to prove the account
+func verificationCode() (string, error) {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "mostful-" + hex.EncodeToString(buf), nil
+}
+
+// checkAuthor makes sure the lab's pull request was opened by the student's
+// linked account. The message says why the lab can't be taken, empty if it
+// can. Only GitHub accounts can be linked, so students who have one or have
+// to have one only get GitHub labs in.
+func (b *Bot) checkAuthor(ctx context.Context, mmstID string, pr *forge.PullRequest, lab *database.Submission) string {
+	acc, err := b.store.GetForgeAccount(ctx, mmstID, string(forge.GitHub))
+	if errors.Is(err, sql.ErrNoRows) {
+		acc = nil
+	} else if err != nil {
+		log.Printf("Unable to find GitHub account of %s: %s", mmstID, err)
+		return "Unable to check your GitHub account, try again later"
+	}
+	if acc != nil && acc.AnyAuthor {
+		return ""
+	}
+	linked := acc != nil && acc.Verified
+	if !linked && !b.cfg.RequireLinkedAccount {
+		return ""
+	}
+	switch {
+	case pr.Kind != forge.GitHub:
+		return fmt.Sprintf("Only GitHub pull requests are taken from students with a linked GitHub account, ask an admin about %s", pr.Host)
+	case !linked:
+		return "Link your GitHub account with /linkgithub first"
+	case b.pulls == nil:
+		log.Printf("Unable to check the author of %s, github_api isn't set", lab.Url)
+		return "Unable to check who opened the pull request, ask an admin"
+	case lab.PullAuthor == "":
+		return "Unable to check who opened the pull request, try again later"
+	case !strings.EqualFold(acc.Login, lab.PullAuthor):
+		return fmt.Sprintf("This pull request was opened by %s, but your GitHub account is %s. Ask an admin if it's yours anyway", lab.PullAuthor, acc.Login)
+	}
+	return ""
+}
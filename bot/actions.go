@@ -7,16 +7,102 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/utils"
 )
 
 type actionObject struct {
 	Type              string `json:"type"`
 	Lab               int    `json:"lab"`
 	OriginalMessageID string
+	UserID            string
+	TriggerID         string
+}
+
+// labAction makes a button on a lab post that comes back to dispatchActions
+func (b *Bot) labAction(actionType, name string, lab int64) *model.PostAction {
+	return &model.PostAction{
+		// Mattermost chokes on action IDs with underscores, and takes the
+		// first action with the ID in a post, /queue has buttons of many labs
+		Id:   strings.ReplaceAll(actionType, "_", "") + strconv.FormatInt(lab, 10),
+		Type: "button",
+		Name: name,
+		Integration: &model.PostActionIntegration{
+			URL: fmt.Sprintf("%s/actions", b.ownUrl),
+			Context: map[string]interface{}{
+				"action": map[string]interface{}{
+					"type": actionType,
+					"lab":  lab,
+				},
+			},
+		},
+	}
+}
+
+// labActions are the buttons on the mentor's post of a lab in its state
+func (b *Bot) labActions(lab *database.Submission) []*model.SlackAttachment {
+	var actions []*model.PostAction
+	switch lab.State {
+	case database.StateSubmitted:
+		actions = append(actions,
+			b.labAction("start_review", "Start review", lab.ID),
+			b.labAction("approve", "Approve", lab.ID),
+			b.labAction("request_changes", "Request changes", lab.ID),
+			b.labAction("reassign", "Reassign", lab.ID))
+	case database.StateInReview:
+		actions = append(actions,
+			b.labAction("approve", "Approve", lab.ID),
+			b.labAction("request_changes", "Request changes", lab.ID),
+			b.labAction("reassign", "Reassign", lab.ID))
+	case database.StateChangesRequested:
+		actions = append(actions,
+			b.labAction("approve", "Approve", lab.ID),
+			b.labAction("reassign", "Reassign", lab.ID))
+	case database.StateApproved:
+		actions = append(actions, b.labAction("disapprove", "Disapprove", lab.ID))
+	}
+	return []*model.SlackAttachment{{Actions: actions}}
+}
+
+// sendLab posts the lab with its buttons to the mentor and remembers the post
+func (b *Bot) sendLab(mentor *database.Mentor, lab *database.Submission, msg string) {
+	post, err := utils.SendDM(b.user.Id, mentor.MmstID, msg+pullSummary(lab), b.labActions(lab), b.client)
+	if err != nil {
+		log.Printf("Unable to send lab %d to @%s: %s", lab.ID, mentor.Tag, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := b.store.SetLabPost(ctx, lab, post.Id); err != nil {
+		log.Printf("Unable to save post of lab %d: %s", lab.ID, err)
+	}
+}
+
+// mayReview reports whether the user is the lab's mentor or an admin
+func (b *Bot) mayReview(ctx context.Context, lab *database.Submission, userID string) bool {
+	if mentor, err := b.store.GetMentorById(ctx, lab.MentorID); err == nil && mentor.MmstID == userID {
+		return true
+	}
+	ok, err := b.store.CheckAdmin(ctx, &database.Admin{MmstID: userID})
+	return err == nil && ok
+}
+
+// denyAction answers a button pressed by someone who may not review the lab
+func denyAction(resp http.ResponseWriter) {
+	failAction(resp, "You have no permission!")
+}
+
+// failAction answers a button that couldn't do its job with what went wrong
+func failAction(resp http.ResponseWriter, text string) {
+	update, _ := json.Marshal(model.PostActionIntegrationResponse{
+		EphemeralText: text,
+	})
+	resp.Write(update)
 }
 
 func (b *Bot) dispatchActions(resp http.ResponseWriter, req *http.Request) {
@@ -36,15 +122,30 @@ func (b *Bot) dispatchActions(resp http.ResponseWriter, req *http.Request) {
 	body, err := io.ReadAll(req.Body)
 	var requestBody model.PostActionIntegrationRequest
 	err = json.Unmarshal(body, &requestBody)
+	// the context is whatever the button was made with, posts from older
+	// versions or forged requests may have anything there
+	action, ok := requestBody.Context["action"].(map[string]interface{})
+	actionType, typeOk := action["type"].(string)
+	lab, labOk := action["lab"].(float64)
+	if err != nil || !ok || !typeOk || !labOk {
+		resp.WriteHeader(400)
+		resp.Write([]byte("Unable to parse action"))
+		return
+	}
 	actionCtx := actionObject{
-		Type:              requestBody.Context["action"].(map[string]interface{})["type"].(string),
-		Lab:               int(requestBody.Context["action"].(map[string]interface{})["lab"].(float64)),
+		Type:              actionType,
+		Lab:               int(lab),
 		OriginalMessageID: requestBody.PostId,
+		UserID:            requestBody.UserId,
+		TriggerID:         requestBody.TriggerId,
 	}
 
 	dispatchMap := map[string]func(resp http.ResponseWriter, action *actionObject){
-		"approve":    b.approveLab,
-		"disapprove": b.disapproveLab,
+		"approve":         b.approveLab,
+		"disapprove":      b.disapproveLab,
+		"request_changes": b.requestChanges,
+		"start_review":    b.startReview,
+		"reassign":        b.reassignAction,
 	}
 
 	if dispatchMap[actionCtx.Type] != nil {
@@ -52,92 +153,108 @@ func (b *Bot) dispatchActions(resp http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// approveLab approves the lab right away, or asks for its score first if
+// it's worth any
 func (b *Bot) approveLab(resp http.ResponseWriter, action *actionObject) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	lab := database.Lab{
-		ID: int64(action.Lab),
-	}
-	err := database.DB.GetLabPK(ctx, &lab)
+	lab, err := b.store.GetSubmission(ctx, int64(action.Lab))
 	if err != nil {
-		log.Printf("Something went wrong: %s", err)
+		log.Printf("Something went wrong at finishing, db.GetSubmission: %s", err)
+		failAction(resp, "Unable to find the lab, try again later")
 		return
 	}
-	err = database.DB.FinishLab(ctx, &lab)
-	if err != nil {
-		log.Printf("Something went wrong at finishing: %s", err)
+	if !b.mayReview(ctx, lab, action.UserID) {
+		denyAction(resp)
 		return
 	}
-
-	postAction := model.PostAction{
-		Id:   "disapprove",
-		Type: "button",
-		Name: "Disapprove",
-		Integration: &model.PostActionIntegration{
-			URL: fmt.Sprintf("%s/actions", b.ownUrl),
-			Context: map[string]interface{}{
-				"action": map[string]interface{}{
-					"type": "disapprove",
-					"lab":  action.Lab,
-				},
-			},
-		},
+	if b.maxScore(lab) > 0 {
+		b.askScore(resp, action, lab)
+		return
 	}
-	attachment := model.SlackAttachment{
-		Actions: []*model.PostAction{&postAction},
+	err = b.store.FinishLab(ctx, lab, action.UserID)
+	if err != nil {
+		log.Printf("Something went wrong at finishing: %s", err)
+		failAction(resp, "Unable to save, try again later")
+		return
 	}
-	op, _, _ := b.client.GetPost(context.Background(), action.OriginalMessageID, "")
-	post := model.Post{
-		Message: op.Message,
+	if stud, err := b.store.GetStudentById(ctx, lab.StudentID); err == nil {
+		go utils.SendDM(b.user.Id, stud.MmstID, fmt.Sprintf("Lab %s approved!", lab.Url), nil, b.client)
 	}
-	post.AddProp("attachments", []*model.SlackAttachment{&attachment})
-	update := model.PostActionIntegrationResponse{
-		Update:           &post,
-		SkipSlackParsing: true,
-	}
-	updatejson, _ := json.Marshal(update)
 	log.Println("Approving...")
-	resp.Write(updatejson)
+	go b.assignQueued()
+	b.updateLabPost(resp, action, lab, "")
 }
 
 func (b *Bot) disapproveLab(resp http.ResponseWriter, action *actionObject) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	lab := database.DoneLab{
-		ID: int64(action.Lab),
-	}
-	err := database.DB.GetDoneLabPK(ctx, &lab)
+	lab, err := b.store.GetSubmission(ctx, int64(action.Lab))
 	if err != nil {
-		log.Printf("Something went wrong: %s", err)
+		log.Printf("Something went wrong at unfinishing, db.GetSubmission: %s", err)
+		failAction(resp, "Unable to find the lab, try again later")
 		return
 	}
-	err = database.DB.UnfinishLab(ctx, &lab)
+	if !b.mayReview(ctx, lab, action.UserID) {
+		denyAction(resp)
+		return
+	}
+	err = b.store.UnfinishLab(ctx, lab, action.UserID)
 	if err != nil {
 		log.Printf("Something went wrong at Unfinishing: %s", err)
+		failAction(resp, "Unable to save, try again later")
 		return
 	}
-	postAction := model.PostAction{
-		Id:   "approve",
-		Type: "button",
-		Name: "Approve",
-		Integration: &model.PostActionIntegration{
-			URL: fmt.Sprintf("%s/actions", b.ownUrl),
-			Context: map[string]interface{}{
-				"action": map[string]interface{}{
-					"type": "approve",
-					"lab":  action.Lab,
-				},
-			},
-		},
+	b.updateLabPost(resp, action, lab, "")
+}
+
+func (b *Bot) startReview(resp http.ResponseWriter, action *actionObject) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	lab, err := b.store.GetSubmission(ctx, int64(action.Lab))
+	if err != nil {
+		log.Printf("Something went wrong at starting review, db.GetSubmission: %s", err)
+		failAction(resp, "Unable to find the lab, try again later")
+		return
+	}
+	if !b.mayReview(ctx, lab, action.UserID) {
+		denyAction(resp)
+		return
 	}
-	attachment := model.SlackAttachment{
-		Actions: []*model.PostAction{&postAction},
+	err = b.store.StartReview(ctx, lab, action.UserID)
+	if err != nil {
+		log.Printf("Something went wrong at starting review: %s", err)
+		failAction(resp, "Unable to save, try again later")
+		return
+	}
+	b.updateLabPost(resp, action, lab, "👀 In review")
+}
+
+// updateLabPost answers an action with the original post, the buttons of the
+// lab's new state and the note appended, if any. Actions from elsewhere, like
+// /queue, update the lab's own post, if it has one, and tell the user what
+// became of it.
+func (b *Bot) updateLabPost(resp http.ResponseWriter, action *actionObject, lab *database.Submission, note string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if lab.PostID != "" && lab.PostID != action.OriginalMessageID {
+		b.refreshLabPost(ctx, lab, note)
+		respondLabState(resp, lab)
+		return
+	}
+	op, err := b.client.GetPost(ctx, action.OriginalMessageID)
+	if err != nil || op == nil {
+		log.Printf("Unable to get post %s of lab %d: %v", action.OriginalMessageID, lab.ID, err)
+		respondLabState(resp, lab)
+		return
 	}
-	op, _, _ := b.client.GetPost(context.Background(), action.OriginalMessageID, "")
 	post := model.Post{
 		Message: op.Message,
 	}
-	post.AddProp("attachments", []*model.SlackAttachment{&attachment})
+	if note != "" {
+		post.Message += "\n" + note
+	}
+	post.AddProp("attachments", b.labActions(lab))
 	update := model.PostActionIntegrationResponse{
 		Update:           &post,
 		SkipSlackParsing: true,
@@ -146,10 +263,44 @@ func (b *Bot) disapproveLab(resp http.ResponseWriter, action *actionObject) {
 	resp.Write(updatejson)
 }
 
+// respondLabState tells whoever pressed the button what state the lab is in
+func respondLabState(resp http.ResponseWriter, lab *database.Submission) {
+	update := model.PostActionIntegrationResponse{
+		EphemeralText: fmt.Sprintf("Lab %s is %s now", lab.Url, strings.ReplaceAll(lab.State, "_", " ")),
+	}
+	updatejson, _ := json.Marshal(update)
+	resp.Write(updatejson)
+}
+
+func (b *Bot) requestChanges(resp http.ResponseWriter, action *actionObject) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	dialog := model.OpenDialogRequest{
+		TriggerId: action.TriggerID,
+		URL:       fmt.Sprintf("%s/dialogs", b.ownUrl),
+		Dialog: model.Dialog{
+			CallbackId: "request_changes",
+			Title:      "Request changes",
+			Elements: []model.DialogElement{{
+				DisplayName: "What should be fixed?",
+				Name:        "comment",
+				Type:        "textarea",
+			}},
+			SubmitLabel: "Send",
+			State:       fmt.Sprintf("%d:%s", action.Lab, action.OriginalMessageID),
+		},
+	}
+	err := b.client.OpenInteractiveDialog(ctx, dialog)
+	if err != nil {
+		log.Printf("Something went wrong at opening dialog: %s", err)
+	}
+	resp.Write([]byte("{}"))
+}
+
 func (b *Bot) selfCheck(resp http.ResponseWriter, req *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
-	err := database.DB.CheckConnection(ctx)
+	err := b.store.CheckConnection(ctx)
 	if err != nil {
 		resp.WriteHeader(500)
 		resp.Write([]byte("db broken, fixme!"))
@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/utils"
+)
+
+const awayDate = "2006-01-02"
+
+// away lets a mentor stop getting labs for a while:
+//
+//	/away                          - from now until /away off
+//	/away 2026-11-01               - from now, back on the 1st of November
+//	/away 2026-10-25 2026-11-01    - from the 25th of October, back on the 1st
+//	/away off                      - back right now
+//
+// Adding "reassign" hands their open labs over to others once they're gone.
+func (b *Bot) away(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", "application/json")
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to parse form"))
+		log.Println("Something went wrong with parsing the url: ", err.Error())
+		return
+	}
+	if req.Form.Get("token") != b.cfg.Away {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong token secret"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	mentor, err := b.store.GetMentorByTag(ctx, req.Form.Get("user_name"))
+	if err != nil {
+		utils.RespondEphemeral(resp, "You are not a mentor!")
+		return
+	}
+	args := strings.Fields(req.Form.Get("text"))
+	if len(args) == 1 && args[0] == "off" {
+		err = b.store.SetMentorAway(ctx, mentor, time.Time{}, time.Time{}, false)
+		if err != nil {
+			log.Printf("Something went wrong at coming back, db.SetMentorAway: %s", err)
+			utils.RespondEphemeral(resp, "Unable to save, try again later")
+			return
+		}
+		go b.assignQueued()
+		utils.RespondEphemeral(resp, "Welcome back! Labs will come to you again")
+		return
+	}
+	handoff := false
+	if len(args) > 0 && args[len(args)-1] == "reassign" {
+		handoff = true
+		args = args[:len(args)-1]
+	}
+	now := time.Now()
+	from, until := now, time.Time{}
+	var dates []time.Time
+	for _, arg := range args {
+		date, err := time.ParseInLocation(awayDate, arg, time.Local)
+		if err != nil {
+			utils.RespondEphemeral(resp, "Dates must look like 2026-11-01")
+			return
+		}
+		dates = append(dates, date)
+	}
+	switch len(dates) {
+	case 0:
+	case 1:
+		until = dates[0]
+	case 2:
+		from, until = dates[0], dates[1]
+	default:
+		utils.RespondEphemeral(resp, "Usage: /away [from] [back on] [reassign], or /away off")
+		return
+	}
+	if !until.IsZero() && (!until.After(from) || !until.After(now)) {
+		utils.RespondEphemeral(resp, "You'd be back before you're gone!")
+		return
+	}
+	err = b.store.SetMentorAway(ctx, mentor, from, until, handoff)
+	if err != nil {
+		log.Printf("Something went wrong at going away, db.SetMentorAway: %s", err)
+		utils.RespondEphemeral(resp, "Unable to save, try again later")
+		return
+	}
+	text := "You are away"
+	if from.After(now) {
+		text += " from " + from.Format(awayDate)
+	}
+	if until.IsZero() {
+		text += " until /away off"
+	} else {
+		text += ", back on " + until.Format(awayDate)
+	}
+	if handoff && mentor.Away(now) {
+		report, _ := b.handOff(ctx, mentor)
+		text += "\n" + report
+	} else if handoff {
+		text += ", your open labs will be reassigned once you're gone"
+	}
+	utils.RespondEphemeral(resp, text)
+}
+
+// handOff reassigns the open labs of a mentor who went away and reports how
+// it went, and whether any lab moved. The mentor stays up for a hand-off
+// until every lab is gone, WatchAway tries the rest again.
+func (b *Bot) handOff(ctx context.Context, mentor *database.Mentor) (string, bool) {
+	report, moved, stuck := "", false, false
+	for _, lab := range mentor.Submissions.Open() {
+		to, err := b.reassign(ctx, lab, "")
+		if err != nil {
+			log.Printf("Something went wrong at handing off lab %d: %s", lab.ID, err)
+			report += fmt.Sprintf("%s stays with you for now, unable to reassign\n", lab.Url)
+			stuck = true
+			continue
+		}
+		report += fmt.Sprintf("%s → @%s\n", lab.Url, to.Tag)
+		moved = true
+	}
+	if stuck {
+		return report, moved
+	}
+	if err := b.store.SetMentorAway(ctx, mentor, mentor.AwayFrom, mentor.AwayUntil, false); err != nil {
+		log.Printf("Something went wrong at handing off, db.SetMentorAway: %s", err)
+	}
+	return report, moved
+}
+
+// WatchAway hands off the labs of mentors whose leave has begun and lets
+// the ones whose leave is over know they're back, and gives the queue to
+// them.
+func (b *Bot) WatchAway(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		mentors, err := b.store.GetMentors(ctx)
+		if err != nil {
+			log.Printf("Something went wrong at checking leaves: %s", err)
+			cancel()
+			continue
+		}
+		now := time.Now()
+		for i := range mentors {
+			mentor := &mentors[i]
+			switch {
+			case mentor.AwayHandoff && mentor.Away(now):
+				// labs that can't move yet are only told about once some do
+				report, moved := b.handOff(ctx, mentor)
+				if moved {
+					go utils.SendDM(b.user.Id, mentor.MmstID, "Your labs were handed over while you're away:\n"+report, nil, b.client)
+				}
+			case !mentor.AwayUntil.IsZero() && !now.Before(mentor.AwayUntil):
+				err := b.store.SetMentorAway(ctx, mentor, time.Time{}, time.Time{}, false)
+				if err != nil {
+					log.Printf("Something went wrong at ending leave of @%s: %s", mentor.Tag, err)
+					continue
+				}
+				go utils.SendDM(b.user.Id, mentor.MmstID, "Welcome back! Labs will come to you again", nil, b.client)
+			}
+		}
+		cancel()
+		b.assignQueued()
+	}
+}
@@ -1,22 +1,72 @@
 package bot
 
 import (
-	"context"
-	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 
 	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/zinstack625/mostful_manager/config"
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/forge"
+	"github.com/zinstack625/mostful_manager/mattermost"
 )
 
 type Bot struct {
-	client   *model.Client4
-	user     *model.User
-	wsclient *model.WebSocketClient
+	client mattermost.Client
+	user   *model.User
+
+	store  database.Store
+	cfg    *config.Config
+	forges *forge.Forges
+	pulls  forge.API
+	mux    *http.ServeMux
 
 	privatechannelid string
 	debugchannelid   string
-	ownUrl			 string
+	ownUrl           string
+}
+
+// Options is everything a Bot needs to run. User is the bot's own account.
+type Options struct {
+	Client mattermost.Client
+	User   *model.User
+
+	Store  database.Store
+	Config *config.Config
+	// Forges default to the public ones
+	Forges *forge.Forges
+	// Pulls, if set, is asked about GitHub pull requests on submission
+	Pulls forge.API
+
+	OwnUrl           string
+	PrivateChannelID string
+	DebugChannelID   string
+}
+
+func New(opts Options) *Bot {
+	b := &Bot{
+		client:           opts.Client,
+		user:             opts.User,
+		store:            opts.Store,
+		cfg:              opts.Config,
+		forges:           opts.Forges,
+		pulls:            opts.Pulls,
+		mux:              http.NewServeMux(),
+		privatechannelid: opts.PrivateChannelID,
+		debugchannelid:   opts.DebugChannelID,
+		ownUrl:           opts.OwnUrl,
+	}
+	if b.forges == nil {
+		b.forges, _ = forge.New(nil)
+	}
+	b.setupWebHooks()
+	return b
+}
+
+// Handler serves the slash commands and interactive actions of the bot
+func (b *Bot) Handler() http.Handler {
+	return b.mux
 }
 
 func (b *Bot) SetupGracefulShutdown() {
@@ -24,37 +74,21 @@ func (b *Bot) SetupGracefulShutdown() {
 	signal.Notify(c, os.Interrupt)
 	go func() {
 		for range c {
-			if b.wsclient != nil {
-				b.wsclient.Close()
-			}
+			b.client.Close()
 
 			os.Exit(0)
 		}
 	}()
 }
 
-func (b *Bot) Init(instanceUrl, ownUrl, token, username, pchanID, dchanID string) {
-	var err error
-	b.wsclient, err = model.NewWebSocketClient4(fmt.Sprintf("wss://%s", instanceUrl), token)
-	if err != nil {
-		panic(err)
-	}
-	b.client = model.NewAPIv4Client(fmt.Sprintf("https://%s", instanceUrl))
-	b.client.SetToken(token)
-	b.user, _, err = b.client.GetUserByUsername(context.Background(), username, "")
-	if err != nil {
-		panic(err)
-	}
-	b.ownUrl = ownUrl
-	b.privatechannelid = dchanID
-	b.debugchannelid = pchanID
-	b.wsclient.Listen()
+// Listen starts handling websocket events
+func (b *Bot) Listen() {
+	b.client.Listen()
 	go func() {
-		for resp := range b.wsclient.EventChannel {
+		for resp := range b.client.Events() {
 			b.handleResp(resp)
 		}
 	}()
-	go b.SetupWebHooks()
 }
 
 func (b *Bot) handleResp(resp *model.WebSocketEvent) {
@@ -0,0 +1,640 @@
+package bot_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/zinstack625/mostful_manager/bot"
+	"github.com/zinstack625/mostful_manager/config"
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/forge"
+	"github.com/zinstack625/mostful_manager/mattermost"
+)
+
+const testConfig = `{
+	"check_me": "checkme",
+	"labs": "labs",
+	"withdraw": "withdraw",
+	"resubmit": "resubmit",
+	"away": "away",
+	"github_webhook_secret": "s3cret",
+	"gitlab_webhook_token": "t0ken",
+	"approve_on_merge": true,
+	"courses": {
+		"c": {"reject_overdue": true},
+		"scored": {"max_score": 10},
+		"ci": {"ci": "required"},
+		"advice": {"ci": "advisory"}
+	},
+	"lab_patterns": [
+		{"course": "c", "pattern": "^https://github.com/o/01-lab-.*$", "hint": "01-lab-NN repositories"},
+		{"course": "c", "pattern": "^https://gitlab.com/o/01-lab-.*$"},
+		{"course": "scored", "pattern": "^https://github.com/o/02-lab-.*$"},
+		{"course": "ci", "pattern": "^https://github.com/o/03-lab-.*$"},
+		{"course": "advice", "pattern": "^https://github.com/o/04-lab-.*$"}
+	]
+}`
+
+// env is the bot running over a fake Mattermost and a fresh SQLite
+// database, with a mentor and a student in it. GitHub is asked about pull
+// requests only if there's a fake of it.
+type env struct {
+	t       *testing.T
+	srv     *httptest.Server
+	store   database.Store
+	fake    *mattermost.Fake
+	bot     *model.User
+	mentor  *model.User
+	student *model.User
+}
+
+func newEnv(t *testing.T, pulls *forge.Fake) *env {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := database.Init(ctx, "sqlite://"+filepath.Join(dir, "test.db"), database.LeastLoaded{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(cfgPath, []byte(testConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := mattermost.NewFake()
+	t.Cleanup(fake.Close)
+	e := &env{
+		t:       t,
+		store:   store,
+		fake:    fake,
+		bot:     fake.AddUser(&model.User{Username: "bot"}),
+		mentor:  fake.AddUser(&model.User{Username: "mentor"}),
+		student: fake.AddUser(&model.User{Username: "student"}),
+	}
+	if err := store.AddMentor(ctx, &database.Mentor{MmstID: e.mentor.Id, Tag: e.mentor.Username}); err != nil {
+		t.Fatal(err)
+	}
+	e.srv = httptest.NewServer(nil)
+	opts := bot.Options{
+		Store:  store,
+		Config: cfg,
+		Client: fake,
+		User:   e.bot,
+		OwnUrl: e.srv.URL,
+	}
+	if pulls != nil {
+		opts.Pulls = pulls
+	}
+	e.srv.Config.Handler = bot.New(opts).Handler()
+	t.Cleanup(e.srv.Close)
+	return e
+}
+
+// command runs the slash command as the user and returns the text of the
+// ephemeral response
+func (e *env) command(path, token string, user *model.User, text string) string {
+	e.t.Helper()
+	form := url.Values{
+		"token":     {token},
+		"user_id":   {user.Id},
+		"user_name": {user.Username},
+		"text":      {text},
+	}
+	resp, err := e.srv.Client().PostForm(e.srv.URL+path, form)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		e.t.Fatalf("%s: %s %s", path, resp.Status, body)
+	}
+	var cmd model.CommandResponse
+	if err := json.Unmarshal(body, &cmd); err != nil {
+		e.t.Fatalf("%s: %s in %s", path, err, body)
+	}
+	return cmd.Text
+}
+
+// click presses the button of the post as the user
+func (e *env) click(post *model.Post, button string, user *model.User) *model.PostActionIntegrationResponse {
+	e.t.Helper()
+	var action *model.PostAction
+	for _, attachment := range post.Attachments() {
+		for _, a := range attachment.Actions {
+			if a.Name == button {
+				action = a
+			}
+		}
+	}
+	if action == nil {
+		e.t.Fatalf("no %q button on %q", button, post.Message)
+	}
+	body, _ := json.Marshal(model.PostActionIntegrationRequest{
+		UserId:  user.Id,
+		PostId:  post.Id,
+		Context: action.Integration.Context,
+	})
+	resp, err := e.srv.Client().Post(action.Integration.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var update model.PostActionIntegrationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&update); err != nil {
+		e.t.Fatal(err)
+	}
+	return &update
+}
+
+// dms waits for the bot to send the user n direct messages
+func (e *env) dms(user *model.User, n int) []*model.Post {
+	e.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		dms := e.fake.DMs(e.bot.Id, user.Id)
+		if len(dms) >= n {
+			return dms
+		}
+		if time.Now().After(deadline) {
+			e.t.Fatalf("@%s got %d messages, want %d", user.Username, len(dms), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCheckmeApprove(t *testing.T) {
+	e := newEnv(t, nil)
+	lab := "https://github.com/o/01-lab-03-student/pull/1"
+	if got := e.command("/checkme", "checkme", e.student, lab); !strings.Contains(got, "@mentor") {
+		t.Errorf("checkme answered %q, want it to name the mentor", got)
+	}
+	post := e.dms(e.mentor, 1)[0]
+	if !strings.Contains(post.Message, lab) {
+		t.Errorf("mentor got %q, want the lab in it", post.Message)
+	}
+
+	update := e.click(post, "Approve", e.mentor)
+	if update.Update == nil {
+		t.Fatalf("approve answered %+v, want the post updated", update)
+	}
+	if buttons := update.Update.Attachments(); len(buttons) == 0 || len(buttons[0].Actions) != 1 || buttons[0].Actions[0].Name != "Disapprove" {
+		t.Errorf("approved lab has buttons %+v, want Disapprove only", buttons)
+	}
+	if got := e.dms(e.student, 2)[1].Message; !strings.Contains(got, "approved") {
+		t.Errorf("student got %q, want the lab approved", got)
+	}
+
+	labs := e.command("/labs", "labs", e.mentor, "")
+	if !strings.Contains(labs, "student") || !strings.Contains(labs, "✅") {
+		t.Errorf("/labs shows %q, want the student's approved lab", labs)
+	}
+	if mine := e.command("/labs", "labs", e.student, ""); !strings.Contains(mine, "✅") {
+		t.Errorf("/labs shows the student %q, want their approved lab", mine)
+	}
+}
+
+func TestCheckmeRejects(t *testing.T) {
+	e := newEnv(t, nil)
+	if got := e.command("/checkme", "checkme", e.student, "https://example.com/o/repo/pull/1"); !strings.Contains(got, "01-lab-NN") {
+		t.Errorf("checkme answered %q to a bad link, want the hint", got)
+	}
+	lab := "https://github.com/o/01-lab-03-student/pull/1"
+	e.command("/checkme", "checkme", e.student, lab)
+	if got := e.command("/checkme", "checkme", e.student, lab); !strings.Contains(got, "already") {
+		t.Errorf("checkme answered %q to the same lab, want it turned away", got)
+	}
+	resp, err := e.srv.Client().PostForm(e.srv.URL+"/checkme", url.Values{"token": {"wrong"}, "text": {lab}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("wrong token got %s, want 403", resp.Status)
+	}
+}
+
+func TestActionsNeedReviewer(t *testing.T) {
+	e := newEnv(t, nil)
+	e.command("/checkme", "checkme", e.student, "https://github.com/o/01-lab-03-student/pull/1")
+	post := e.dms(e.mentor, 1)[0]
+	for _, button := range []string{"Start review", "Approve", "Reassign"} {
+		update := e.click(post, button, e.student)
+		if update.Update != nil || !strings.Contains(update.EphemeralText, "permission") {
+			t.Errorf("%s by the student answered %+v, want it denied", button, update)
+		}
+	}
+	stud, err := e.store.GetStudentByTag(context.Background(), e.student.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := stud.Submissions[0].State; state != database.StateSubmitted {
+		t.Errorf("lab is %s after the student's clicks, want it submitted", state)
+	}
+	if mentor := stud.Submissions[0].MentorID; mentor == 0 {
+		t.Error("lab lost its mentor to the student's clicks")
+	}
+
+	approved := e.click(post, "Approve", e.mentor).Update
+	approved.Id = post.Id
+	if update := e.click(approved, "Disapprove", e.student); !strings.Contains(update.EphemeralText, "permission") {
+		t.Errorf("Disapprove by the student answered %+v, want it denied", update)
+	}
+}
+
+func TestActionsAnswerFailures(t *testing.T) {
+	e := newEnv(t, nil)
+	e.command("/checkme", "checkme", e.student, "https://github.com/o/01-lab-03-student/pull/1")
+	post := e.dms(e.mentor, 1)[0]
+	// the mentor is the only one, nobody can take the lab over
+	if update := e.click(post, "Reassign", e.mentor); update.EphemeralText == "" {
+		t.Errorf("reassign without other mentors answered %+v, want to be told why", update)
+	}
+
+	// the buttons of a lab that isn't there
+	for _, attachment := range post.Attachments() {
+		for _, a := range attachment.Actions {
+			a.Integration.Context["action"].(map[string]interface{})["lab"] = 1000
+		}
+	}
+	for _, button := range []string{"Start review", "Approve", "Reassign"} {
+		if update := e.click(post, button, e.mentor); update.EphemeralText == "" {
+			t.Errorf("%s of a missing lab answered %+v, want to be told why", button, update)
+		}
+	}
+}
+
+func TestMalformedActions(t *testing.T) {
+	e := newEnv(t, nil)
+	for _, body := range []string{
+		`not json`,
+		`{}`,
+		`{"context":{"action":"approve"}}`,
+		`{"context":{"action":{"type":"approve"}}}`,
+		`{"context":{"action":{"type":"approve","lab":"1"}}}`,
+		`{"context":{"action":{"type":1,"lab":1}}}`,
+	} {
+		resp, err := e.srv.Client().Post(e.srv.URL+"/actions", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s got %s, want 400", body, resp.Status)
+		}
+	}
+}
+
+func TestGitHubWebhookSignature(t *testing.T) {
+	e := newEnv(t, nil)
+	const body = `{"action":"opened"}`
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(body))
+	tests := []struct {
+		name      string
+		signature string
+		want      int
+	}{
+		{"signed", "sha256=" + hex.EncodeToString(mac.Sum(nil)), http.StatusNoContent},
+		{"wrong", "sha256=" + strings.Repeat("0", 64), http.StatusForbidden},
+		{"unsigned", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, e.srv.URL+"/webhooks/github", strings.NewReader(body))
+			req.Header.Set("X-GitHub-Event", "pull_request")
+			req.Header.Set("X-Hub-Signature-256", tt.signature)
+			resp, err := e.srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("got %s, want %d", resp.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckmeChecksAuthor(t *testing.T) {
+	const (
+		own     = "https://github.com/o/01-lab-01-student/pull/1"
+		other   = "https://github.com/o/01-lab-02-student/pull/1"
+		gitlab  = "https://gitlab.com/o/01-lab-03-student/-/merge_requests/1"
+		unknown = "https://github.com/o/01-lab-04-student/pull/1"
+	)
+	pulls := forge.NewFake()
+	pulls.AddPullRequest(own, &forge.PullInfo{Title: "mine", Author: "Student-GH", State: "open"})
+	pulls.AddPullRequest(other, &forge.PullInfo{Title: "theirs", Author: "someone", State: "open"})
+	pulls.AddPullRequest(unknown, &forge.PullInfo{Title: "mine too", Author: "student-gh", State: "open"})
+	e := newEnv(t, pulls)
+	err := e.store.SetForgeAccount(context.Background(), &database.ForgeAccount{
+		MmstID:   e.student.Id,
+		Forge:    string(forge.GitHub),
+		Login:    "student-gh",
+		Verified: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pulls.SetDown(errors.New("rate limited"))
+	if got := e.command("/checkme", "checkme", e.student, unknown); !strings.Contains(got, "try again later") {
+		t.Errorf("checkme with GitHub down answered %q, want it turned away", got)
+	}
+	pulls.SetDown(nil)
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{other, "opened by someone"},
+		{gitlab, "Only GitHub pull requests"},
+		{own, "assigned to @mentor"},
+		{unknown, "assigned to @mentor"},
+	}
+	for _, tt := range tests {
+		if got := e.command("/checkme", "checkme", e.student, tt.url); !strings.Contains(got, tt.want) {
+			t.Errorf("%s: checkme answered %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestCheckmeWithoutCI(t *testing.T) {
+	const (
+		required = "https://github.com/o/03-lab-01-student/pull/1"
+		advisory = "https://github.com/o/04-lab-01-student/pull/1"
+	)
+	pulls := forge.NewFake()
+	pulls.AddPullRequest(required, &forge.PullInfo{Title: "lab", Author: "student", State: "open", HeadSHA: "abc"})
+	pulls.AddPullRequest(advisory, &forge.PullInfo{Title: "lab", Author: "student", State: "open", HeadSHA: "def"})
+	e := newEnv(t, pulls)
+	pulls.SetCIDown(errors.New("rate limited"))
+	if got := e.command("/checkme", "checkme", e.student, required); !strings.Contains(got, "try again later") {
+		t.Errorf("checkme with the CI unknown answered %q, want it turned away", got)
+	}
+	if got := e.command("/checkme", "checkme", e.student, advisory); !strings.Contains(got, "assigned to @mentor") {
+		t.Errorf("checkme with advisory CI unknown answered %q, want it taken", got)
+	}
+	pulls.SetCIDown(nil)
+	pulls.SetCI("abc", forge.CISuccess)
+	if got := e.command("/checkme", "checkme", e.student, required); !strings.Contains(got, "assigned to @mentor") {
+		t.Errorf("checkme with the CI back answered %q, want it taken", got)
+	}
+}
+
+func TestHandOffRetriesStuckLabs(t *testing.T) {
+	e := newEnv(t, nil)
+	ctx := context.Background()
+	lab := "https://github.com/o/01-lab-03-student/pull/1"
+	e.command("/checkme", "checkme", e.student, lab)
+	if got := e.command("/away", "away", e.mentor, "reassign"); !strings.Contains(got, "stays with you") {
+		t.Errorf("away with nobody to take the lab answered %q", got)
+	}
+	mentor, err := e.store.GetMentorByTag(ctx, e.mentor.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mentor.AwayHandoff {
+		t.Error("hand-off given up with a lab left behind")
+	}
+
+	other := e.fake.AddUser(&model.User{Username: "other"})
+	if err := e.store.AddMentor(ctx, &database.Mentor{MmstID: other.Id, Tag: other.Username}); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.command("/away", "away", e.mentor, "reassign"); !strings.Contains(got, "→ @other") {
+		t.Errorf("away with someone to take the lab answered %q", got)
+	}
+	mentor, err = e.store.GetMentorByTag(ctx, e.mentor.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mentor.AwayHandoff {
+		t.Error("hand-off still pending with every lab moved")
+	}
+}
+
+// lab finds the student's lab by its URL
+func (e *env) lab(url string) *database.Submission {
+	e.t.Helper()
+	subs, err := e.store.GetSubmissionsByUrl(context.Background(), url)
+	if err != nil || len(subs) != 1 {
+		e.t.Fatalf("%s: %d labs, %v", url, len(subs), err)
+	}
+	return &subs[0]
+}
+
+func (e *env) setDeadline(number int64, soft, hard time.Time) {
+	e.t.Helper()
+	err := e.store.SetDeadline(context.Background(), &database.Deadline{Course: "c", Number: number, SoftAt: soft, HardAt: hard})
+	if err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+func TestResentLabsAreLateAgain(t *testing.T) {
+	e := newEnv(t, nil)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	const (
+		withdrawn = "https://github.com/o/01-lab-01-student/pull/1"
+		moved     = "https://github.com/o/01-lab-02-student/pull/1"
+		movedTo   = "https://github.com/o/01-lab-02-student/pull/2"
+		fixed     = "https://github.com/o/01-lab-03-student/pull/1"
+	)
+	for _, url := range []string{withdrawn, moved, fixed} {
+		if got := e.command("/checkme", "checkme", e.student, url); !strings.Contains(got, "assigned") {
+			t.Fatalf("%s: checkme answered %q", url, got)
+		}
+	}
+	e.command("/withdraw", "withdraw", e.student, "1")
+	e.command("/withdraw", "withdraw", e.student, "2")
+	if err := e.store.RequestChanges(ctx, e.lab(fixed), e.mentor.Id, "fix"); err != nil {
+		t.Fatal(err)
+	}
+
+	e.setDeadline(1, past, time.Time{})
+	e.setDeadline(2, past.Add(-time.Hour), past)
+	e.setDeadline(3, past.Add(-time.Hour), past)
+
+	if got := e.command("/checkme", "checkme", e.student, withdrawn); !strings.Contains(got, "sent back") {
+		t.Errorf("withdrawn lab sent again got %q", got)
+	}
+	if late := e.lab(withdrawn).Lateness; late != database.Late {
+		t.Errorf("withdrawn lab sent again after the deadline is %q, want late", late)
+	}
+
+	if got := e.command("/resubmit", "resubmit", e.student, "2 "+movedTo); !strings.Contains(got, "not taken anymore") {
+		t.Errorf("withdrawn lab moved after the hard deadline got %q, want it turned away", got)
+	}
+	if lab := e.lab(moved); lab.State != database.StateWithdrawn || lab.Lateness != database.OnTime {
+		t.Errorf("turned away lab is %s and %q, want it left alone", lab.State, lab.Lateness)
+	}
+
+	if got := e.command("/checkme", "checkme", e.student, fixed); !strings.Contains(got, "sent back") {
+		t.Errorf("fixed lab got %q, want it taken", got)
+	}
+	if late := e.lab(fixed).Lateness; late != database.OnTime {
+		t.Errorf("fixed lab is %q, want it as on time as it was sent", late)
+	}
+}
+
+// hook sends the GitHub webhook event, signed
+func (e *env) hook(event, body string) int {
+	e.t.Helper()
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(body))
+	req, _ := http.NewRequest(http.MethodPost, e.srv.URL+"/webhooks/github", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	resp, err := e.srv.Client().Do(req)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestMergeApproves(t *testing.T) {
+	e := newEnv(t, nil)
+	err := e.store.SetForgeAccount(context.Background(), &database.ForgeAccount{
+		MmstID:   e.mentor.Id,
+		Forge:    string(forge.GitHub),
+		Login:    "mentor-gh",
+		Verified: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		plain  = "https://github.com/o/01-lab-01-student/pull/1"
+		scored = "https://github.com/o/02-lab-01-student/pull/1"
+	)
+	merged := `{"action":"closed","pull_request":{"html_url":"%s","merged":true},"sender":{"login":"mentor-gh"}}`
+	for _, url := range []string{plain, scored} {
+		e.command("/checkme", "checkme", e.student, url)
+		if status := e.hook("pull_request", fmt.Sprintf(merged, url)); status != http.StatusNoContent {
+			t.Errorf("%s: merge got %d", url, status)
+		}
+	}
+	if state := e.lab(plain).State; state != database.StateApproved {
+		t.Errorf("merged lab is %s, want it approved", state)
+	}
+	if lab := e.lab(scored); lab.State == database.StateApproved || lab.Score != nil {
+		t.Errorf("merged scored lab is %s with score %v, want it left for scoring", lab.State, lab.Score)
+	}
+	dms := e.dms(e.mentor, 3)
+	if got := dms[len(dms)-1].Message; !strings.Contains(got, "score it") {
+		t.Errorf("mentor got %q, want to be asked for the score", got)
+	}
+}
+
+func TestMergeOnGitLabOnlyTells(t *testing.T) {
+	e := newEnv(t, nil)
+	for _, kind := range []forge.Kind{forge.GitHub, forge.GitLab} {
+		err := e.store.SetForgeAccount(context.Background(), &database.ForgeAccount{
+			MmstID:   e.mentor.Id,
+			Forge:    string(kind),
+			Login:    "mentor",
+			Verified: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	const url = "https://gitlab.com/o/01-lab-01-student/-/merge_requests/1"
+	e.command("/checkme", "checkme", e.student, url)
+	body := fmt.Sprintf(`{"object_kind":"merge_request","user":{"username":"mentor"},"object_attributes":{"action":"merge","url":"%s"}}`, url)
+	req, _ := http.NewRequest(http.MethodPost, e.srv.URL+"/webhooks/gitlab", strings.NewReader(body))
+	req.Header.Set("X-Gitlab-Token", "t0ken")
+	resp, err := e.srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("merge got %d", resp.StatusCode)
+	}
+	if state := e.lab(url).State; state == database.StateApproved {
+		t.Error("lab merged on GitLab approved, want the mentor only told")
+	}
+	dms := e.dms(e.mentor, 2)
+	if got := dms[len(dms)-1].Message; !strings.Contains(got, "was merged by mentor") {
+		t.Errorf("mentor got %q, want to be told about the merge", got)
+	}
+}
+
+func TestDuplicatesOnEveryPath(t *testing.T) {
+	e := newEnv(t, nil)
+	const (
+		first  = "https://github.com/o/01-lab-03-student/pull/1"
+		second = "https://github.com/o/01-lab-03-student/pull/2"
+		third  = "https://github.com/o/01-lab-03-student/pull/3"
+	)
+	e.command("/checkme", "checkme", e.student, first)
+	e.command("/withdraw", "withdraw", e.student, first)
+	if got := e.command("/checkme", "checkme", e.student, second); !strings.Contains(got, "assigned") {
+		t.Fatalf("lab sent again after withdrawing got %q", got)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		token   string
+		text    string
+		refused string
+	}{
+		{"same link in other case", "/checkme", "checkme", "https://github.com/O/01-Lab-03-Student/pull/2", "Lab already added"},
+		{"withdrawn one again", "/checkme", "checkme", first, "You have already sent lab 3: " + second},
+		{"withdrawn one moved", "/resubmit", "resubmit", first + " " + third, "You have already sent lab 3: " + second},
+	}
+	for _, tt := range tests {
+		if got := e.command(tt.path, tt.token, e.student, tt.text); !strings.Contains(got, tt.refused) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.refused)
+		}
+	}
+	stud, err := e.store.GetStudentByTag(context.Background(), e.student.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if open := stud.Submissions.Open(); len(open) != 1 || open[0].Url != second {
+		t.Errorf("student has open labs %v, want only %s", open, second)
+	}
+}
+
+func TestActionWithoutLabPost(t *testing.T) {
+	e := newEnv(t, nil)
+	const url = "https://github.com/o/01-lab-03-student/pull/1"
+	e.command("/checkme", "checkme", e.student, url)
+	post := e.dms(e.mentor, 1)[0]
+	// like a lab whose post never made it, pressed in /queue
+	if err := e.store.SetLabPost(context.Background(), e.lab(url), ""); err != nil {
+		t.Fatal(err)
+	}
+	post.Id = "ephemeral"
+	update := e.click(post, "Start review", e.mentor)
+	if update.Update != nil || !strings.Contains(update.EphemeralText, "in review") {
+		t.Errorf("got %+v, want to be told the lab is in review", update)
+	}
+	if state := e.lab(url).State; state != database.StateInReview {
+		t.Errorf("lab is %s, want it in review", state)
+	}
+}
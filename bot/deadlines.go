@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/utils"
+)
+
+const deadlineFormat = "2006-01-02 15:04"
+
+// deadline manages when labs are due:
+//
+//	/deadline                                  - list them
+//	/deadline 3 2026-11-01                     - lab 3 is due by the end of the 1st of November
+//	/deadline cbeer 3 2026-11-01 2026-11-15    - in course cbeer, with a hard deadline
+//	/deadline 3 2026-11-01T18:00               - by 18:00
+//	/deadline cbeer 3 off
+//
+// The course may be left out for the labs of the default pattern.
+func (b *Bot) deadline(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", "application/json")
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to parse form"))
+		log.Println("Something went wrong with parsing the url: ", err.Error())
+		return
+	}
+	if req.Form.Get("token") != b.cfg.Deadline {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong token secret"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if ok, err := b.store.CheckAdmin(ctx, &database.Admin{
+		MmstID: req.Form.Get("user_id"),
+		Tag:    req.Form.Get("user_name"),
+	}); err != nil || !ok {
+		utils.RespondEphemeral(resp, "You have no permission!")
+		return
+	}
+	args := strings.Fields(req.Form.Get("text"))
+	if len(args) == 0 {
+		deadlines, err := b.store.GetDeadlines(ctx)
+		if err != nil {
+			log.Printf("Something went wrong at listing deadlines, db.GetDeadlines: %s", err)
+			utils.RespondEphemeral(resp, "Unable to list deadlines, try again later")
+			return
+		}
+		if len(deadlines) == 0 {
+			utils.RespondEphemeral(resp, "No deadlines, labs are never late")
+			return
+		}
+		report := ""
+		for i := range deadlines {
+			report += describeDeadline(&deadlines[i]) + "\n"
+		}
+		utils.RespondEphemeral(resp, report)
+		return
+	}
+	dl := &database.Deadline{}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		dl.Course, args = args[0], args[1:]
+	}
+	if len(args) < 2 || len(args) > 3 {
+		utils.RespondEphemeral(resp, "Usage: /deadline [course] <lab> <due> [hard], or /deadline [course] <lab> off")
+		return
+	}
+	dl.Number, err = strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		utils.RespondEphemeral(resp, "Lab number must be a number!")
+		return
+	}
+	if args[1] == "off" {
+		if err := b.store.RemoveDeadline(ctx, dl); err != nil {
+			log.Printf("Something went wrong at removing deadline, db.RemoveDeadline: %s", err)
+			utils.RespondEphemeral(resp, "Unable to save, try again later")
+			return
+		}
+		utils.RespondEphemeral(resp, fmt.Sprintf("%s has no deadline now", labName(dl.Course, dl.Number)))
+		return
+	}
+	dl.SoftAt, err = parseDeadline(args[1])
+	if err == nil && len(args) == 3 {
+		dl.HardAt, err = parseDeadline(args[2])
+	}
+	if err != nil {
+		utils.RespondEphemeral(resp, "Dates must look like 2026-11-01 or 2026-11-01T18:00")
+		return
+	}
+	if !dl.HardAt.IsZero() && dl.HardAt.Before(dl.SoftAt) {
+		utils.RespondEphemeral(resp, "The hard deadline can't come before the soft one!")
+		return
+	}
+	if err := b.store.SetDeadline(ctx, dl); err != nil {
+		log.Printf("Something went wrong at setting deadline, db.SetDeadline: %s", err)
+		utils.RespondEphemeral(resp, "Unable to save, try again later")
+		return
+	}
+	utils.RespondEphemeral(resp, describeDeadline(dl))
+}
+
+// parseDeadline takes a date, meaning the end of that day, or a date and time
+func parseDeadline(value string) (time.Time, error) {
+	if at, err := time.ParseInLocation("2006-01-02T15:04", value, time.Local); err == nil {
+		return at, nil
+	}
+	day, err := time.ParseInLocation(awayDate, value, time.Local)
+	if err != nil {
+		return day, err
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Minute), nil
+}
+
+func labName(course string, number int64) string {
+	if course == "" {
+		return fmt.Sprintf("Lab %d", number)
+	}
+	return fmt.Sprintf("Lab %d of %s", number, course)
+}
+
+func describeDeadline(dl *database.Deadline) string {
+	text := fmt.Sprintf("%s is due %s", labName(dl.Course, dl.Number), dl.SoftAt.Format(deadlineFormat))
+	if !dl.HardAt.IsZero() {
+		text += ", at the latest " + dl.HardAt.Format(deadlineFormat)
+	}
+	return text
+}
+
+// checkDeadline tells how late the lab is and fills it in. The message says
+// why the lab can't be taken, empty if it can.
+func (b *Bot) checkDeadline(ctx context.Context, lab *database.Submission) (*database.Deadline, string) {
+	dl, err := b.store.GetDeadline(ctx, lab.Course, lab.Number)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Unable to find deadline of lab %s: %s", lab.Url, err)
+		}
+		return nil, ""
+	}
+	lab.Lateness = dl.Lateness(time.Now())
+	if lab.Lateness == database.Overdue && b.cfg.Course(lab.Course).RejectOverdue {
+		return dl, fmt.Sprintf("The deadline of this lab was %s, it's not taken anymore", dl.HardAt.Format(deadlineFormat))
+	}
+	return dl, ""
+}
+
+// latenessRank orders how late labs can be
+var latenessRank = map[string]int{
+	database.OnTime:  0,
+	database.Late:    1,
+	database.Overdue: 2,
+}
+
+// checkReturn is checkDeadline for a lab sent again. A withdrawn one is as
+// late as it is now, if that's worse than it was at first, and it's saved.
+// Others never left, how late they were first sent stands.
+func (b *Bot) checkReturn(ctx context.Context, lab *database.Submission) string {
+	if lab.State != database.StateWithdrawn {
+		return ""
+	}
+	again := *lab
+	if _, msg := b.checkDeadline(ctx, &again); msg != "" {
+		return msg
+	}
+	if latenessRank[again.Lateness] <= latenessRank[lab.Lateness] {
+		return ""
+	}
+	lab.Lateness = again.Lateness
+	if err := b.store.SetLateness(ctx, lab); err != nil {
+		log.Printf("Something went wrong at saving lateness of lab %d, db.SetLateness: %s", lab.ID, err)
+		return "Unable to resubmit the lab, try again later"
+	}
+	return ""
+}
+
+// dueNote tells the student whether the lab made it in time
+func dueNote(lab *database.Submission, dl *database.Deadline) string {
+	if dl == nil {
+		return ""
+	}
+	switch lab.Lateness {
+	case database.Late:
+		return fmt.Sprintf(". It's late, the deadline was %s", dl.SoftAt.Format(deadlineFormat))
+	case database.Overdue:
+		return fmt.Sprintf(". It's overdue, the last deadline was %s", dl.HardAt.Format(deadlineFormat))
+	}
+	return ". It's on time"
+}
+
+var lateMarks = map[string]string{
+	database.Late:    "⏰",
+	database.Overdue: "⌛",
+}
@@ -0,0 +1,33 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDeadline(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{"2026-10-01T18:30", time.Date(2026, 10, 1, 18, 30, 0, 0, time.Local), true},
+		// a day is due by its end
+		{"2026-10-01", time.Date(2026, 10, 1, 23, 59, 0, 0, time.Local), true},
+		{"2026-12-31", time.Date(2026, 12, 31, 23, 59, 0, 0, time.Local), true},
+		{"2026-02-30", time.Time{}, false},
+		{"2026-10-01 18:30", time.Time{}, false},
+		{"tomorrow", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseDeadline(tt.value)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v, want ok %v", err, tt.ok)
+			}
+			if tt.ok && !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
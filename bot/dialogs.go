@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/zinstack625/mostful_manager/utils"
+)
+
+func (b *Bot) dispatchDialogs(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", "application/json")
+	var submission model.SubmitDialogRequest
+	err := json.NewDecoder(req.Body).Decode(&submission)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to parse dialog submission"))
+		log.Println("Something went wrong with parsing the dialog: ", err.Error())
+		return
+	}
+	if submission.Cancelled {
+		resp.Write([]byte("{}"))
+		return
+	}
+
+	dispatchMap := map[string]func(resp http.ResponseWriter, submission *model.SubmitDialogRequest){
+		"request_changes": b.submitChanges,
+		"approve":         b.submitScore,
+	}
+
+	if dispatchMap[submission.CallbackId] != nil {
+		dispatchMap[submission.CallbackId](resp, &submission)
+	}
+}
+
+// respondDialog answers a dialog submission, an empty errors map closes the
+// dialog, otherwise the errors are shown next to the named fields
+func respondDialog(resp http.ResponseWriter, errors map[string]string) {
+	answer, _ := json.Marshal(model.SubmitDialogResponse{Errors: errors})
+	resp.Write(answer)
+}
+
+// labState splits the "lab:post" state of lab dialogs
+func labState(state string) (int64, string, error) {
+	lab, post, _ := strings.Cut(state, ":")
+	labID, err := strconv.ParseInt(lab, 10, 64)
+	return labID, post, err
+}
+
+func (b *Bot) submitChanges(resp http.ResponseWriter, submission *model.SubmitDialogRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	labID, postID, err := labState(submission.State)
+	if err != nil {
+		log.Printf("Something went wrong at requesting changes, bad state %q: %s", submission.State, err)
+		return
+	}
+	comment, _ := submission.Submission["comment"].(string)
+	if strings.TrimSpace(comment) == "" {
+		respondDialog(resp, map[string]string{"comment": "Tell the student what to fix"})
+		return
+	}
+	lab, err := b.store.GetSubmission(ctx, labID)
+	if err != nil || !lab.Open() {
+		respondDialog(resp, map[string]string{"comment": "The lab is not in review anymore"})
+		return
+	}
+	// the dialog may be opened from /queue, it's the lab's own post that's
+	// updated
+	if lab.PostID != "" {
+		postID = lab.PostID
+	}
+	mentor, err := b.store.GetMentorById(ctx, lab.MentorID)
+	if err != nil {
+		log.Printf("Something went wrong at requesting changes, db.GetMentorById: %s", err)
+		respondDialog(resp, map[string]string{"comment": "Unable to save, try again later"})
+		return
+	}
+	if !b.mayReview(ctx, lab, submission.UserId) {
+		respondDialog(resp, map[string]string{"comment": "You have no permission!"})
+		return
+	}
+	err = b.store.RequestChanges(ctx, lab, submission.UserId, comment)
+	if err != nil {
+		log.Printf("Something went wrong at requesting changes, db.RequestChanges: %s", err)
+		respondDialog(resp, map[string]string{"comment": "Unable to save, try again later"})
+		return
+	}
+	if stud, err := b.store.GetStudentById(ctx, lab.StudentID); err == nil {
+		msg := fmt.Sprintf("@%s asked for changes in lab %s:\n\n%s\n\nFix it and /checkme the same link again", mentor.Tag, lab.Url, quote(comment))
+		go utils.SendDM(b.user.Id, stud.MmstID, msg, nil, b.client)
+	}
+	if op, err := b.client.GetPost(ctx, postID); err == nil {
+		op.Message += "\n✏️ Changes requested"
+		op.AddProp("attachments", b.labActions(lab))
+		if _, err := b.client.UpdatePost(ctx, op); err != nil {
+			log.Printf("Unable to update post of lab %d: %s", lab.ID, err)
+		}
+	}
+	respondDialog(resp, nil)
+}
+
+// quote turns text into a markdown blockquote
+func quote(text string) string {
+	return "> " + strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n> ")
+}
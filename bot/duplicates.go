@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/zinstack625/mostful_manager/config"
+	"github.com/zinstack625/mostful_manager/database"
+)
+
+// checkDuplicate applies the course's duplicates policy to a lab the student
+// has already sent another pull request for, as a new lab or as one coming
+// back to review. It returns the earlier lab when the new pull request should
+// take its place, or a message when it's turned away, neither when it's a lab
+// of its own.
+func (b *Bot) checkDuplicate(ctx context.Context, lab *database.Submission) (*database.Submission, string) {
+	// labs without a number can't be told apart
+	if lab.Number == 0 {
+		return nil, ""
+	}
+	subs, err := b.store.GetSubmissionsByNumber(ctx, lab)
+	if err != nil {
+		log.Printf("Something went wrong at checking duplicates, db.GetSubmissionsByNumber: %s", err)
+		return nil, "Unable to check the lab, try again later"
+	}
+	var open, approved *database.Submission
+	for i := range subs {
+		sub := &subs[i]
+		switch {
+		case sub.ID == lab.ID:
+		case sub.Open() && open == nil:
+			open = sub
+		case sub.State == database.StateApproved && approved == nil:
+			approved = sub
+		}
+	}
+	policy := b.cfg.Course(lab.Course).Duplicates
+	// only a new pull request can take an earlier one's place
+	back := lab.ID != 0
+	switch {
+	case approved != nil && policy != config.DuplicatesRetake:
+		return nil, fmt.Sprintf("Lab %d is already approved: %s", lab.Number, approved.Url)
+	case open == nil:
+		return nil, ""
+	case back:
+		return nil, fmt.Sprintf("You have already sent lab %d: %s, /withdraw it first", lab.Number, open.Url)
+	case policy == config.DuplicatesResubmit:
+		return open, ""
+	case policy == config.DuplicatesRetake:
+		return nil, fmt.Sprintf("Lab %d is still in review: %s, /resubmit it with the new link or /withdraw it first", lab.Number, open.Url)
+	}
+	return nil, fmt.Sprintf("You have already sent lab %d: %s, /resubmit it with the new link or /withdraw it first", lab.Number, open.Url)
+}
@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/utils"
+)
+
+// WatchLoad periodically compares stored mentor loads with the ones derived
+// from their labs and reports any drift to the debug channel.
+func (b *Bot) WatchLoad(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		drifts, err := b.store.CheckLoad(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("Something went wrong at checking load: %s", err)
+			continue
+		}
+		if len(drifts) == 0 {
+			continue
+		}
+		post := model.Post{
+			ChannelId: b.debugchannelid,
+			Message:   "Mentor load drifted, run /recalcload to fix it\n" + formatDrifts(drifts),
+		}
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = b.client.CreatePost(ctx, &post)
+		cancel()
+		if err != nil {
+			log.Printf("Unable to report load drift: %s", err)
+		}
+	}
+}
+
+func (b *Bot) recalcLoad(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", "application/json")
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to parse form"))
+		log.Println("Something went wrong with parsing the url: ", err.Error())
+		return
+	}
+	if req.Form.Get("token") != b.cfg.RecalcLoad {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong token secret"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if ok, err := b.store.CheckAdmin(ctx, &database.Admin{
+		MmstID: req.Form.Get("user_id"),
+		Tag:    req.Form.Get("user_name"),
+	}); err != nil || !ok {
+		utils.RespondEphemeral(resp, "You have no permission!")
+		return
+	}
+	drifts, err := b.store.RecalcLoad(ctx)
+	if err != nil {
+		log.Printf("Something went wrong at recalculating load: %s", err)
+		utils.RespondEphemeral(resp, "Unable to recalculate load!")
+		return
+	}
+	if len(drifts) == 0 {
+		utils.RespondEphemeral(resp, "All loads are correct")
+		return
+	}
+	utils.RespondEphemeral(resp, "Fixed:\n"+formatDrifts(drifts))
+}
+
+func formatDrifts(drifts []database.LoadDrift) string {
+	markdown := "Mentor | Stored | Actual\n--- | --- | ---\n"
+	for _, drift := range drifts {
+		markdown += fmt.Sprintf("@%s | %d | %d\n", drift.Mentor.Tag, drift.Stored, drift.Actual)
+	}
+	return markdown
+}
@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/zinstack625/mostful_manager/config"
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/forge"
+)
+
+// checkPull asks the forge about the lab's pull request and fills the lab
+// in. The message says why the lab can't be taken, empty if it can. Labs are
+// turned away when the forge can't be reached, only GitHub is asked.
+func (b *Bot) checkPull(ctx context.Context, pr *forge.PullRequest, lab *database.Submission) string {
+	if b.pulls == nil || pr.Kind != forge.GitHub {
+		return ""
+	}
+	info, err := b.pulls.PullRequest(ctx, pr)
+	if errors.Is(err, forge.ErrNoPullRequest) {
+		return "There's no such pull request. Is the repository private?"
+	}
+	if err != nil {
+		log.Printf("Unable to fetch pull request %s: %s", lab.Url, err)
+		return "Unable to check the pull request on GitHub, try again later"
+	}
+	if info.Merged {
+		return "This pull request is already merged, only open ones get reviewed"
+	}
+	if !info.Open() {
+		return "This pull request is closed, reopen it to get it reviewed"
+	}
+	lab.PullTitle = info.Title
+	lab.PullAuthor = info.Author
+	lab.PullState = info.State
+	lab.HeadSHA = info.HeadSHA
+	lab.ChangedFiles = info.ChangedFiles
+	return ""
+}
+
+// checkCI asks the forge about the CI of the lab's head commit, as far as
+// the course's policy cares. The message says why the lab can't be taken,
+// empty if it can. Labs whose CI is still running under the required policy
+// are parked until it passes, and turned away when the forge can't say.
+func (b *Bot) checkCI(ctx context.Context, pr *forge.PullRequest, lab *database.Submission) string {
+	policy := b.cfg.Course(lab.Course).CI
+	if b.pulls == nil || lab.HeadSHA == "" || policy == config.CIOff {
+		return ""
+	}
+	state, err := b.pulls.CIStatus(ctx, pr, lab.HeadSHA)
+	if err != nil {
+		log.Printf("Unable to fetch CI status of %s: %s", lab.Url, err)
+		if policy == config.CIRequired {
+			return "Unable to check the CI on GitHub, try again later"
+		}
+		return ""
+	}
+	lab.CIState = string(state)
+	if policy != config.CIRequired {
+		return ""
+	}
+	switch state {
+	case forge.CIFailure:
+		return fmt.Sprintf("CI fails on %.7s, fix it and /checkme again", lab.HeadSHA)
+	case forge.CIPending:
+		lab.State = database.StateAwaitingCI
+	}
+	return ""
+}
+
+var ciMarks = map[string]string{
+	string(forge.CISuccess): "CI ✅",
+	string(forge.CIFailure): "CI ❌",
+	string(forge.CIPending): "CI ⏳",
+}
+
+// pullSummary is a line about the lab's pull request for its mentor
+func pullSummary(lab *database.Submission) string {
+	if lab.PullTitle == "" {
+		return ""
+	}
+	summary := fmt.Sprintf("\n> **%s** by %s, %d files changed, head %.7s", lab.PullTitle, lab.PullAuthor, lab.ChangedFiles, lab.HeadSHA)
+	if mark, ok := ciMarks[lab.CIState]; ok {
+		summary += ", " + mark
+	}
+	return summary
+}
@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/utils"
+)
+
+// assignQueued gives queued labs to mentors who have room for them and
+// lets everyone involved know
+func (b *Bot) assignQueued() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	assigned, err := b.store.AssignQueued(ctx)
+	if err != nil {
+		log.Printf("Something went wrong at assigning the queue: %s", err)
+		return
+	}
+	for i := range assigned {
+		lab := &assigned[i]
+		mentor, err := b.store.GetMentorById(ctx, lab.MentorID)
+		if err != nil {
+			log.Printf("Something went wrong at assigning the queue, db.GetMentorById: %s", err)
+			continue
+		}
+		stud, err := b.store.GetStudentById(ctx, lab.StudentID)
+		if err != nil {
+			log.Printf("Something went wrong at assigning the queue, db.GetStudentById: %s", err)
+			continue
+		}
+		go utils.SendDM(b.user.Id, stud.MmstID, fmt.Sprintf("Lab %s, assigned to @%s", lab.Url, mentor.Tag), nil, b.client)
+		go b.sendLab(mentor, lab, fmt.Sprintf("@%s: %s", stud.Tag, lab.Url))
+	}
+}
+
+// capacity shows or sets how many open labs a mentor may have
+func (b *Bot) capacity(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", "application/json")
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to parse form"))
+		log.Println("Something went wrong with parsing the url: ", err.Error())
+		return
+	}
+	if req.Form.Get("token") != b.cfg.Capacity {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong token secret"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if ok, err := b.store.CheckAdmin(ctx, &database.Admin{
+		MmstID: req.Form.Get("user_id"),
+		Tag:    req.Form.Get("user_name"),
+	}); err != nil || !ok {
+		utils.RespondEphemeral(resp, "You have no permission!")
+		return
+	}
+	args := strings.Fields(req.Form.Get("text"))
+	if len(args) < 1 {
+		utils.RespondEphemeral(resp, "Must supply mentor Tag, optionally followed by capacity!")
+		return
+	}
+	mentor, err := b.store.GetMentorByTag(ctx, strings.TrimPrefix(args[0], "@"))
+	if err != nil {
+		utils.RespondEphemeral(resp, "No such mentor!")
+		return
+	}
+	if len(args) == 1 {
+		if mentor.Capacity == 0 {
+			utils.RespondEphemeral(resp, fmt.Sprintf("@%s has %d open labs, no cap", mentor.Tag, len(mentor.Submissions.Open())))
+			return
+		}
+		utils.RespondEphemeral(resp, fmt.Sprintf("@%s has %d of %d open labs", mentor.Tag, len(mentor.Submissions.Open()), mentor.Capacity))
+		return
+	}
+	capacity, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || capacity < 0 {
+		utils.RespondEphemeral(resp, "Capacity must be a number, 0 for no cap")
+		return
+	}
+	err = b.store.SetMentorCapacity(ctx, mentor, capacity)
+	if err != nil {
+		log.Printf("Something went wrong at setting capacity, db.SetMentorCapacity: %s", err)
+		utils.RespondEphemeral(resp, "Unable to set capacity!")
+		return
+	}
+	go b.assignQueued()
+	utils.RespondEphemeral(resp, "Done!")
+}
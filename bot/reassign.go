@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/utils"
+)
+
+// reassign hands the lab over to another mentor, strikes it out of the post
+// it was reviewed from and lets the new mentor and the student know
+func (b *Bot) reassign(ctx context.Context, lab *database.Submission, actor string) (database.Mentor, error) {
+	mentor, err := b.store.ReassignLab(ctx, lab, actor)
+	if err != nil {
+		return mentor, err
+	}
+	b.strikeLabPost(ctx, lab, fmt.Sprintf("➡️ Reassigned to @%s", mentor.Tag))
+	if stud, err := b.store.GetStudentById(ctx, lab.StudentID); err == nil {
+		go b.sendLab(&mentor, lab, fmt.Sprintf("@%s: %s (handed over to you)", stud.Tag, lab.Url))
+		go utils.SendDM(b.user.Id, stud.MmstID, fmt.Sprintf("Lab %s is now reviewed by @%s", lab.Url, mentor.Tag), nil, b.client)
+	} else {
+		go b.sendLab(&mentor, lab, fmt.Sprintf("%s (handed over to you)", lab.Url))
+	}
+	return mentor, nil
+}
+
+func (b *Bot) reassignAction(resp http.ResponseWriter, action *actionObject) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	lab, err := b.store.GetSubmission(ctx, int64(action.Lab))
+	if err != nil {
+		log.Printf("Something went wrong at reassigning, db.GetSubmission: %s", err)
+		failAction(resp, "Unable to find the lab, try again later")
+		return
+	}
+	if !b.mayReview(ctx, lab, action.UserID) {
+		denyAction(resp)
+		return
+	}
+	_, err = b.reassign(ctx, lab, action.UserID)
+	switch {
+	case errors.Is(err, database.ErrNoMentors):
+		failAction(resp, "Nobody else can take the lab now")
+		return
+	case err != nil:
+		log.Printf("Something went wrong at reassigning lab %d: %s", lab.ID, err)
+		failAction(resp, "Unable to reassign the lab, try again later")
+		return
+	}
+	resp.Write([]byte("{}"))
+}
+
+// reassignCmd moves a single lab given by its URL, or every open lab of the
+// given mentor, to other mentors
+func (b *Bot) reassignCmd(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", "application/json")
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to parse form"))
+		log.Println("Something went wrong with parsing the url: ", err.Error())
+		return
+	}
+	if req.Form.Get("token") != b.cfg.Reassign {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong token secret"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if ok, err := b.store.CheckAdmin(ctx, &database.Admin{
+		MmstID: req.Form.Get("user_id"),
+		Tag:    req.Form.Get("user_name"),
+	}); err != nil || !ok {
+		utils.RespondEphemeral(resp, "You have no permission!")
+		return
+	}
+	text := strings.TrimSpace(req.Form.Get("text"))
+	if text == "" {
+		utils.RespondEphemeral(resp, "Must supply a lab URL or a mentor Tag!")
+		return
+	}
+	var labs database.Submissions
+	if strings.HasPrefix(text, "https://") {
+		if pr, err := b.forges.Parse(text); err == nil {
+			text = pr.Canonical()
+		}
+		subs, err := b.store.GetSubmissionsByUrl(ctx, text)
+		if err != nil {
+			log.Printf("Something went wrong at reassigning, db.GetSubmissionsByUrl: %s", err)
+			utils.RespondEphemeral(resp, "Unable to find the lab, try again later")
+			return
+		}
+		for i := range subs {
+			labs = append(labs, &subs[i])
+		}
+	} else {
+		mentor, err := b.store.GetMentorByTag(ctx, strings.TrimPrefix(text, "@"))
+		if err != nil {
+			utils.RespondEphemeral(resp, "No such mentor!")
+			return
+		}
+		labs = mentor.Submissions
+	}
+	labs = labs.Open()
+	if len(labs) == 0 {
+		utils.RespondEphemeral(resp, "No open labs to reassign")
+		return
+	}
+	report := ""
+	for _, lab := range labs {
+		mentor, err := b.reassign(ctx, lab, req.Form.Get("user_id"))
+		switch {
+		case errors.Is(err, database.ErrNoMentors):
+			report += fmt.Sprintf("%s: nobody else to take it\n", lab.Url)
+		case errors.Is(err, database.ErrLabQueued):
+			report += fmt.Sprintf("%s: still in the queue\n", lab.Url)
+		case err != nil:
+			log.Printf("Something went wrong at reassigning lab %d: %s", lab.ID, err)
+			report += fmt.Sprintf("%s: unable to reassign\n", lab.Url)
+		default:
+			report += fmt.Sprintf("%s → @%s\n", lab.Url, mentor.Tag)
+		}
+	}
+	utils.RespondEphemeral(resp, report)
+}
@@ -0,0 +1,177 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/utils"
+)
+
+// queuePage is how many labs /queue shows at once
+const queuePage = 10
+
+// reviewQueue lists the mentor's open labs, the ones waiting longest first,
+// each with its buttons:
+//
+//	/queue                - all of them
+//	/queue lab 3          - only lab 3
+//	/queue late           - only labs sent after their deadline
+//	/queue overdue        - only labs sent after their hard deadline
+//	/queue lab 3 page 2   - the next page
+func (b *Bot) reviewQueue(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", "application/json")
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to parse form"))
+		log.Println("Something went wrong with parsing the url: ", err.Error())
+		return
+	}
+	if req.Form.Get("token") != b.cfg.Queue {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong token secret"))
+		return
+	}
+	const usage = "Usage: /queue [lab <number>] [late|overdue] [page <number>]"
+	var number int64
+	var lateness []string
+	page := 1
+	args := strings.Fields(req.Form.Get("text"))
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "lab", "page":
+			if i+1 == len(args) {
+				utils.RespondEphemeral(resp, usage)
+				return
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				utils.RespondEphemeral(resp, usage)
+				return
+			}
+			if args[i] == "lab" {
+				number = int64(n)
+			} else {
+				page = n
+			}
+			i++
+		case "late":
+			lateness = []string{database.Late, database.Overdue}
+		case "overdue":
+			lateness = []string{database.Overdue}
+		default:
+			utils.RespondEphemeral(resp, usage)
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	mentor, err := b.store.GetMentorByTag(ctx, req.Form.Get("user_name"))
+	if err != nil {
+		utils.RespondEphemeral(resp, "You are not a mentor!")
+		return
+	}
+	var labs database.Submissions
+	for _, lab := range mentor.Submissions.Open() {
+		if number != 0 && lab.Number != number {
+			continue
+		}
+		if lateness != nil && !contains(lateness, lab.Lateness) {
+			continue
+		}
+		labs = append(labs, lab)
+	}
+	if len(labs) == 0 {
+		utils.RespondEphemeral(resp, "Nothing to review")
+		return
+	}
+	sort.SliceStable(labs, func(i, j int) bool {
+		return labs[i].QueuedAt.Before(labs[j].QueuedAt)
+	})
+	pages := (len(labs) + queuePage - 1) / queuePage
+	if page > pages {
+		utils.RespondEphemeral(resp, fmt.Sprintf("There are only %d pages", pages))
+		return
+	}
+	end := page * queuePage
+	if end > len(labs) {
+		end = len(labs)
+	}
+	now := time.Now()
+	var attachments []*model.SlackAttachment
+	for _, lab := range labs[(page-1)*queuePage : end] {
+		attachment := b.labActions(lab)[0]
+		attachment.Text = b.queueLine(ctx, lab, now)
+		attachments = append(attachments, attachment)
+	}
+	text := fmt.Sprintf("%d labs to review", len(labs))
+	if pages > 1 {
+		text += fmt.Sprintf(", page %d of %d", page, pages)
+		if page < pages {
+			next := strings.TrimSpace(strings.Join(withoutPage(args), " ") + fmt.Sprintf(" page %d", page+1))
+			text += fmt.Sprintf(", `/queue %s` for more", next)
+		}
+	}
+	utils.RespondEphemeralAttachments(resp, text, attachments)
+}
+
+// queueLine says whose lab it is, which one and how long it's been waiting
+func (b *Bot) queueLine(ctx context.Context, lab *database.Submission, now time.Time) string {
+	name := "someone"
+	if stud, err := b.store.GetStudentById(ctx, lab.StudentID); err == nil {
+		name = "@" + stud.Tag
+		if stud.RealName != nil {
+			name = fmt.Sprintf("%s (@%s)", *stud.RealName, stud.Tag)
+		}
+	}
+	line := fmt.Sprintf("%s, lab %d, waiting %s: %s", name, lab.Number, waited(now.Sub(lab.QueuedAt)), lab.Url)
+	if lab.State != database.StateSubmitted {
+		line += ", " + strings.ReplaceAll(lab.State, "_", " ")
+	}
+	if mark, ok := lateMarks[lab.Lateness]; ok {
+		line += fmt.Sprintf(" %s %s", mark, lab.Lateness)
+	}
+	return line
+}
+
+// waited rounds the time a lab has been waiting to what matters, like 3d 4h
+func waited(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", d/(24*time.Hour), d%(24*time.Hour)/time.Hour)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", d/time.Hour, d%time.Hour/time.Minute)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
+
+// withoutPage drops the page out of /queue arguments
+func withoutPage(args []string) []string {
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "page" {
+			i++
+			continue
+		}
+		rest = append(rest, args[i])
+	}
+	return rest
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/utils"
+)
+
+// maxScore is the most points the lab is worth, zero if it isn't scored
+func (b *Bot) maxScore(lab *database.Submission) int64 {
+	return b.cfg.Course(lab.Course).MaxScoreOf(lab.Number)
+}
+
+// points is the lab's score after the penalties of its course, false if it
+// has none
+func (b *Bot) points(lab *database.Submission) (int64, bool) {
+	if lab.State != database.StateApproved || lab.Score == nil {
+		return 0, false
+	}
+	course := b.cfg.Course(lab.Course)
+	return course.Points(*lab.Score, lab.Lateness == database.Late, lab.Lateness == database.Overdue, lab.Revisions), true
+}
+
+// scoreNote tells how many points the lab got
+func (b *Bot) scoreNote(lab *database.Submission) string {
+	points, ok := b.points(lab)
+	if !ok {
+		return ""
+	}
+	note := fmt.Sprintf("%d/%d", *lab.Score, b.maxScore(lab))
+	if points != *lab.Score {
+		note += fmt.Sprintf(", %d after penalties", points)
+	}
+	return note
+}
+
+// askScore opens the dialog the mentor scores the lab in
+func (b *Bot) askScore(resp http.ResponseWriter, action *actionObject, lab *database.Submission) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	max := b.maxScore(lab)
+	dialog := model.OpenDialogRequest{
+		TriggerId: action.TriggerID,
+		URL:       fmt.Sprintf("%s/dialogs", b.ownUrl),
+		Dialog: model.Dialog{
+			CallbackId: "approve",
+			Title:      "Approve",
+			Elements: []model.DialogElement{{
+				DisplayName: "Score",
+				Name:        "score",
+				Type:        "text",
+				SubType:     "number",
+				Default:     strconv.FormatInt(max, 10),
+				HelpText:    fmt.Sprintf("From 0 to %d", max),
+			}},
+			SubmitLabel: "Approve",
+			State:       fmt.Sprintf("%d:%s", lab.ID, action.OriginalMessageID),
+		},
+	}
+	err := b.client.OpenInteractiveDialog(ctx, dialog)
+	if err != nil {
+		log.Printf("Something went wrong at opening dialog: %s", err)
+	}
+	resp.Write([]byte("{}"))
+}
+
+func (b *Bot) submitScore(resp http.ResponseWriter, submission *model.SubmitDialogRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	labID, postID, err := labState(submission.State)
+	if err != nil {
+		log.Printf("Something went wrong at scoring, bad state %q: %s", submission.State, err)
+		return
+	}
+	lab, err := b.store.GetSubmission(ctx, labID)
+	if err != nil || !lab.Open() {
+		respondDialog(resp, map[string]string{"score": "The lab is not in review anymore"})
+		return
+	}
+	if lab.PostID != "" {
+		postID = lab.PostID
+	}
+	if !b.mayReview(ctx, lab, submission.UserId) {
+		respondDialog(resp, map[string]string{"score": "You have no permission!"})
+		return
+	}
+	max := b.maxScore(lab)
+	// number fields come back as strings or numbers depending on the client
+	raw := strings.TrimSpace(fmt.Sprint(submission.Submission["score"]))
+	score, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || score < 0 || score > max {
+		respondDialog(resp, map[string]string{"score": fmt.Sprintf("Must be a whole number from 0 to %d", max)})
+		return
+	}
+	err = b.store.GradeLab(ctx, lab, submission.UserId, score)
+	if err != nil {
+		log.Printf("Something went wrong at scoring, db.GradeLab: %s", err)
+		respondDialog(resp, map[string]string{"score": "Unable to save, try again later"})
+		return
+	}
+	note := b.scoreNote(lab)
+	if stud, err := b.store.GetStudentById(ctx, lab.StudentID); err == nil {
+		go utils.SendDM(b.user.Id, stud.MmstID, fmt.Sprintf("Lab %s approved! Score: %s", lab.Url, note), nil, b.client)
+	}
+	go b.assignQueued()
+	if op, err := b.client.GetPost(ctx, postID); err == nil {
+		op.Message += "\n✅ Approved, " + note
+		op.AddProp("attachments", b.labActions(lab))
+		if _, err := b.client.UpdatePost(ctx, op); err != nil {
+			log.Printf("Unable to update post of lab %d: %s", lab.ID, err)
+		}
+	}
+	respondDialog(resp, nil)
+}
@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/utils"
+)
+
+// skills manages which labs a mentor reviews:
+//
+//	/skills @mentor                       - list them
+//	/skills @mentor add 3 4 *-lab-07-*    - lab numbers or repository name globs
+//	/skills @mentor remove 4
+//	/skills @mentor clear
+func (b *Bot) skills(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", "application/json")
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to parse form"))
+		log.Println("Something went wrong with parsing the url: ", err.Error())
+		return
+	}
+	if req.Form.Get("token") != b.cfg.Skills {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong token secret"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if ok, err := b.store.CheckAdmin(ctx, &database.Admin{
+		MmstID: req.Form.Get("user_id"),
+		Tag:    req.Form.Get("user_name"),
+	}); err != nil || !ok {
+		utils.RespondEphemeral(resp, "You have no permission!")
+		return
+	}
+	args := strings.Fields(req.Form.Get("text"))
+	if len(args) < 1 {
+		utils.RespondEphemeral(resp, "Must supply mentor Tag, optionally followed by add, remove or clear!")
+		return
+	}
+	mentor, err := b.store.GetMentorByTag(ctx, strings.TrimPrefix(args[0], "@"))
+	if err != nil {
+		utils.RespondEphemeral(resp, "No such mentor!")
+		return
+	}
+	if len(args) == 1 {
+		if len(mentor.Skills) == 0 {
+			utils.RespondEphemeral(resp, fmt.Sprintf("@%s has no skills, they only get labs nobody else knows", mentor.Tag))
+			return
+		}
+		var list []string
+		for _, skill := range mentor.Skills {
+			list = append(list, skill.String())
+		}
+		utils.RespondEphemeral(resp, fmt.Sprintf("@%s reviews %s", mentor.Tag, strings.Join(list, ", ")))
+		return
+	}
+	switch args[1] {
+	case "clear":
+		err = b.store.ClearSkills(ctx, mentor)
+	case "add", "remove":
+		if len(args) < 3 {
+			utils.RespondEphemeral(resp, "Must supply lab numbers or repository name patterns!")
+			return
+		}
+		var skills []*database.Skill
+		for _, arg := range args[2:] {
+			skill := parseSkill(mentor, arg)
+			if skill == nil {
+				utils.RespondEphemeral(resp, fmt.Sprintf("%q is neither a lab number nor a valid pattern", arg))
+				return
+			}
+			skills = append(skills, skill)
+		}
+		for _, skill := range skills {
+			if args[1] == "add" {
+				err = b.store.AddSkill(ctx, skill)
+			} else {
+				err = b.store.RemoveSkill(ctx, skill)
+			}
+			if err != nil {
+				break
+			}
+		}
+	default:
+		utils.RespondEphemeral(resp, "Must be add, remove or clear!")
+		return
+	}
+	if err != nil {
+		log.Printf("Something went wrong at changing skills: %s", err)
+		utils.RespondEphemeral(resp, "Unable to change skills!")
+		return
+	}
+	utils.RespondEphemeral(resp, "Done!")
+}
+
+// parseSkill makes a skill of a lab number or a repository name glob, nil if
+// it's neither
+func parseSkill(mentor *database.Mentor, arg string) *database.Skill {
+	skill := &database.Skill{MentorID: mentor.ID}
+	if num, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		skill.Number = &num
+		return skill
+	}
+	if _, err := path.Match(arg, ""); err != nil {
+		return nil
+	}
+	skill.Pattern = arg
+	return skill
+}
@@ -2,16 +2,15 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"regexp"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
-	"github.com/zinstack625/mostful_manager/config"
 	"github.com/zinstack625/mostful_manager/database"
 	"github.com/zinstack625/mostful_manager/utils"
 )
@@ -24,72 +23,174 @@ func (b *Bot) checkme(resp http.ResponseWriter, req *http.Request) {
 		resp.Write([]byte("Unable to parse form"))
 		log.Println("Something went wrong with parsing the url: ", err.Error())
 	}
-	if req.Form.Get("token") != config.IntegrationTokens.CheckMe {
+	if req.Form.Get("token") != b.cfg.CheckMe {
 		resp.WriteHeader(403)
 		resp.Write([]byte("Wrong token secret"))
 	}
-	labUrl := req.Form.Get("text")
-	if ok, err := regexp.Match("^https://github.com/.*/(?:(?:[0-9]{2}-lab-[0-9]{2}-.*)|(?:lab-test-[0-9]{1}-.*))/pull/[0-9]{1,}$", []byte(labUrl)); err == nil && !ok {
-		utils.RespondEphemeral(resp, "Does not seem like a lab we check! Make sure the URL is in form of \"https://github.com/bmstu-cbeer-20**/**-lab-**-YourName/pull/1\"")
+	pr, err := b.forges.Parse(req.Form.Get("text"))
+	if err != nil {
+		utils.RespondEphemeral(resp, b.cfg.LabHint())
+		return
+	}
+	labUrl := pr.Canonical()
+	match, ok := b.cfg.MatchLab(labUrl)
+	if !ok {
+		utils.RespondEphemeral(resp, b.cfg.LabHint())
 		return
 	}
+	if !match.Numbered {
+		match.Number = pr.LabNumber()
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	student := &database.Student{
-		MmstID:   req.Form.Get("user_id"),
-		Tag:      req.Form.Get("user_name"),
-		Labs:     []*database.Lab{},
-		DoneLabs: []*database.DoneLab{},
+		MmstID: req.Form.Get("user_id"),
+		Tag:    req.Form.Get("user_name"),
 	}
-	database.DB.AddStudent(ctx, student)
+	b.store.AddStudent(ctx, student)
 
-	labNumScanner, err := regexp.Compile("^https://github.com/.*/(?:(?:[0-9]{2}-lab-([0-9]{2})-.*)|(?:lab-test-([0-9]{1,2})-.*))/pull/[0-9]{1,}$")
-	if err != nil {
-		log.Printf("Unable to compile regexp: %s", err)
-		return
-	}
-	var labNum int64
-	// Already checked that this will match, do not fret
-	labNumString := string(labNumScanner.FindSubmatch([]byte(labUrl))[1])
-	fmt.Sscanf(labNumString, "%d", &labNum)
-	lab := database.Lab{
+	lab := database.Submission{
 		Url:       labUrl,
 		StudentID: student.ID,
-		Number:    labNum,
+		Number:    match.Number,
+		Course:    match.Course,
+	}
+	if msg := b.checkPull(ctx, pr, &lab); msg != "" {
+		utils.RespondEphemeral(resp, msg)
+		return
 	}
-	existing, err := database.DB.GetLabs(ctx, &lab)
+	if msg := b.checkAuthor(ctx, student.MmstID, pr, &lab); msg != "" {
+		utils.RespondEphemeral(resp, msg)
+		return
+	}
+	if msg := b.checkCI(ctx, pr, &lab); msg != "" {
+		utils.RespondEphemeral(resp, msg)
+		return
+	}
+	existing, err := b.store.GetSubmissions(ctx, &lab)
 	if err != nil {
 		log.Println("Unable to connect to database?: ", err.Error())
 	}
 	if len(existing) > 0 {
+		if state := existing[0].State; state != database.StateChangesRequested && state != database.StateWithdrawn {
+			utils.RespondEphemeral(resp, "Lab already added")
+			return
+		}
+		if _, msg := b.checkDuplicate(ctx, &existing[0]); msg != "" {
+			utils.RespondEphemeral(resp, msg)
+			return
+		}
+		if msg := b.checkReturn(ctx, &existing[0]); msg != "" {
+			utils.RespondEphemeral(resp, msg)
+			return
+		}
+		if lab.PullTitle != "" {
+			existing[0].PullTitle, existing[0].PullAuthor, existing[0].PullState = lab.PullTitle, lab.PullAuthor, lab.PullState
+			existing[0].HeadSHA, existing[0].ChangedFiles = lab.HeadSHA, lab.ChangedFiles
+			existing[0].CIState = lab.CIState
+			if err := b.store.SetPullInfo(ctx, &existing[0]); err != nil {
+				log.Printf("Unable to save pull request of lab %d: %s", existing[0].ID, err)
+			}
+		}
+		if lab.State == database.StateAwaitingCI {
+			b.park(resp, student, &existing[0])
+			return
+		}
+		b.resubmit(resp, student, &existing[0])
+		return
+	}
+	earlier, msg := b.checkDuplicate(ctx, &lab)
+	if msg != "" {
+		utils.RespondEphemeral(resp, msg)
+		return
+	}
+	if earlier != nil {
+		b.replaceLab(resp, student, earlier, &lab)
+		return
+	}
+	deadline, msg := b.checkDeadline(ctx, &lab)
+	if msg != "" {
+		utils.RespondEphemeral(resp, msg)
+		return
+	}
+	due := dueNote(&lab, deadline)
+	mentor, err := b.store.AddLab(ctx, &lab)
+	if errors.Is(err, database.ErrLabExists) {
 		utils.RespondEphemeral(resp, "Lab already added")
 		return
 	}
-	mentor, _ := database.DB.AddLab(ctx, &lab)
-	text := fmt.Sprintf("Lab %s, assigned to @%s", lab.Url, mentor.Tag)
+	if err != nil {
+		log.Printf("Something went wrong at adding lab, db.AddLab: %s", err)
+		utils.RespondEphemeral(resp, "Unable to assign the lab, try again later")
+		return
+	}
+	if lab.State == database.StateAwaitingCI {
+		utils.RespondEphemeral(resp, fmt.Sprintf("CI is still running on %.7s, lab %s goes to a mentor once it passes", lab.HeadSHA, lab.Url)+due)
+		return
+	}
+	if lab.State == database.StateQueued {
+		pos, err := b.store.QueuePosition(ctx, &lab)
+		if err != nil {
+			log.Printf("Something went wrong at queueing lab, db.QueuePosition: %s", err)
+		}
+		utils.RespondEphemeral(resp, fmt.Sprintf("All mentors are busy, lab %s is #%d in the queue. You'll get a message once it's assigned", lab.Url, pos)+due)
+		return
+	}
+	text := fmt.Sprintf("Lab %s, assigned to @%s", lab.Url, mentor.Tag) + due
 	defer utils.RespondEphemeral(resp, text)
 	go utils.SendDM(b.user.Id, req.Form.Get("user_id"), text, nil, b.client)
 	mentor_msg := fmt.Sprintf("@%s: %s", student.Tag, lab.Url)
+	if mark, ok := lateMarks[lab.Lateness]; ok {
+		mentor_msg += fmt.Sprintf(" %s %s", mark, lab.Lateness)
+	}
 
-	action := model.PostAction{
-		Id:   "approve",
-		Type: "button",
-		Name: "Approve",
-		Integration: &model.PostActionIntegration{
-			URL: fmt.Sprintf("%s/actions", b.ownUrl),
-			Context: map[string]interface{}{
-				"action": map[string]interface{}{
-					"type": "approve",
-					"lab":  lab.ID,
-				},
-			},
-		},
-	}
-	attachment := model.SlackAttachment{
-		Actions: []*model.PostAction{&action},
+	go b.sendLab(&mentor, &lab, mentor_msg)
+}
+
+// park holds a resubmitted lab back until its CI passes
+func (b *Bot) park(resp http.ResponseWriter, student *database.Student, lab *database.Submission) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := b.store.ParkLab(ctx, lab, student.MmstID)
+	if err != nil {
+		log.Printf("Something went wrong at resubmitting, db.ParkLab: %s", err)
+		utils.RespondEphemeral(resp, "Unable to resubmit the lab, try again later")
+		return
 	}
+	utils.RespondEphemeral(resp, fmt.Sprintf("CI is still running on %.7s, lab %s goes back to review once it passes", lab.HeadSHA, lab.Url))
+}
 
-	go utils.SendDM(b.user.Id, mentor.MmstID, mentor_msg, []*model.SlackAttachment{&attachment}, b.client)
+// resubmit puts a lab the mentor asked to fix back into their queue, labs
+// withdrawn before they got a mentor go to the queue everyone shares
+func (b *Bot) resubmit(resp http.ResponseWriter, student *database.Student, lab *database.Submission) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if lab.MentorID == 0 {
+		err := b.store.Transition(ctx, lab, database.StateQueued, student.MmstID, "")
+		if err != nil {
+			log.Printf("Something went wrong at resubmitting, db.Transition: %s", err)
+			utils.RespondEphemeral(resp, "Unable to resubmit the lab, try again later")
+			return
+		}
+		go b.assignQueued()
+		utils.RespondEphemeral(resp, fmt.Sprintf("Lab %s is back in the queue, you'll get a message once it's assigned", lab.Url))
+		return
+	}
+	mentor, err := b.store.GetMentorById(ctx, lab.MentorID)
+	if err != nil {
+		log.Printf("Something went wrong at resubmitting, db.GetMentorById: %s", err)
+		utils.RespondEphemeral(resp, "Unable to resubmit the lab, try again later")
+		return
+	}
+	err = b.store.ResubmitLab(ctx, lab, student.MmstID)
+	if err != nil {
+		log.Printf("Something went wrong at resubmitting, db.ResubmitLab: %s", err)
+		utils.RespondEphemeral(resp, "Unable to resubmit the lab, try again later")
+		return
+	}
+	utils.RespondEphemeral(resp, fmt.Sprintf("Lab %s sent back to @%s", lab.Url, mentor.Tag))
+	mentor_msg := fmt.Sprintf("@%s fixed: %s", student.Tag, lab.Url)
+	go b.sendLab(mentor, lab, mentor_msg)
 }
 
 func (b *Bot) addmentor(resp http.ResponseWriter, req *http.Request) {
@@ -100,13 +201,13 @@ func (b *Bot) addmentor(resp http.ResponseWriter, req *http.Request) {
 		resp.Write([]byte("Unable to parse form"))
 		log.Println("Something went wrong with parsing the url: ", err.Error())
 	}
-	if req.Form.Get("token") != config.IntegrationTokens.AddMentor {
+	if req.Form.Get("token") != b.cfg.AddMentor {
 		resp.WriteHeader(403)
 		resp.Write([]byte("Wrong token secret"))
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if ok, err := database.DB.CheckAdmin(ctx, &database.Admin{
+	if ok, err := b.store.CheckAdmin(ctx, &database.Admin{
 		MmstID: req.Form.Get("user_id"),
 		Tag:    req.Form.Get("user_name"),
 	}); err != nil || !ok {
@@ -118,10 +219,11 @@ func (b *Bot) addmentor(resp http.ResponseWriter, req *http.Request) {
 		utils.RespondEphemeral(resp, "Must supply Mattermost ID and Tag separated by space!")
 		return
 	}
-	database.DB.AddMentor(ctx, &database.Mentor{
+	b.store.AddMentor(ctx, &database.Mentor{
 		MmstID: args[0],
 		Tag:    args[1],
 	})
+	go b.assignQueued()
 	utils.RespondEphemeral(resp, "Done!")
 }
 
@@ -134,13 +236,13 @@ func (b *Bot) removementor(resp http.ResponseWriter, req *http.Request) {
 		log.Println("Something went wrong with parsing the url: ", err.Error())
 		return
 	}
-	if req.Form.Get("token") != config.IntegrationTokens.RemoveMentor {
+	if req.Form.Get("token") != b.cfg.RemoveMentor {
 		resp.WriteHeader(403)
 		resp.Write([]byte("Wrong token secret"))
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if ok, err := database.DB.CheckAdmin(ctx, &database.Admin{
+	if ok, err := b.store.CheckAdmin(ctx, &database.Admin{
 		MmstID: req.Form.Get("user_id"),
 		Tag:    req.Form.Get("user_name"),
 	}); err != nil || !ok {
@@ -152,7 +254,7 @@ func (b *Bot) removementor(resp http.ResponseWriter, req *http.Request) {
 		utils.RespondEphemeral(resp, "Must supply Mattermost ID and Tag separated by space!")
 		return
 	}
-	database.DB.RemoveMentor(ctx, &database.Mentor{
+	b.store.RemoveMentor(ctx, &database.Mentor{
 		MmstID: args[0],
 		Tag:    args[1],
 		Load:   0,
@@ -163,56 +265,27 @@ func (b *Bot) removementor(resp http.ResponseWriter, req *http.Request) {
 func (b *Bot) myLabs(resp http.ResponseWriter, req *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	stud, err := database.DB.GetStudentByTag(ctx, req.Form.Get("user_id"))
+	stud, err := b.store.GetStudentByTag(ctx, req.Form.Get("user_name"))
 	if err != nil {
-		resp.WriteHeader(500)
-		resp.Write([]byte("Unable to parse form"))
-		log.Println("Something went wrong with parsing the url: ", err.Error())
+		utils.RespondEphemeral(resp, "You have sent no labs yet")
 		return
 	}
-	var report StudentsMarks
-	report.students = make([]StudentReport, 1)
-	studArray, err := database.DB.GetStudents(ctx)
-	labNum := 0
-	min_lab := 1024
-	for _, stud := range studArray {
-		for _, lab := range stud.Labs {
-			if lab.Number > int64(labNum) {
-				labNum = int(lab.Number)
-			}
-			if lab.Number < int64(min_lab) {
-				min_lab = int(lab.Number)
-			}
-		}
-		for _, lab := range stud.DoneLabs {
-			if lab.Number > int64(labNum) {
-				labNum = int(lab.Number)
-			}
-			if lab.Number < int64(min_lab) {
-				min_lab = int(lab.Number)
-			}
-		}
-	}
-	report.students[0].labs = make([]LabState, labNum + 1)
-	for j := range report.students[0].labs {
-		report.students[0].labs[j] = NotReady
-	}
-	if stud.RealName == nil {
-		user, _, err := b.client.GetUsersByIds(context.Background(), []string{stud.MmstID})
-		if err != nil && len(user) > 0 {
-			report.students[0].name = user[0].GetFullName()
-		} else {
-			report.students[0].name = stud.Tag
-		}
-	} else {
-		report.students[0].name = *stud.RealName
+	studArray, err := b.store.GetStudents(ctx)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to get students"))
+		log.Println("Something went wrong at listing students: ", err.Error())
+		return
 	}
-	report.students[0].tag = fmt.Sprintf("@%s", stud.Tag)
-	for _, done_lab := range stud.DoneLabs {
-		report.students[0].labs[done_lab.Number - int64(min_lab)] = Done
+	min_lab, max_lab, ok := labRange(studArray)
+	if !ok {
+		utils.RespondEphemeral(resp, "You have sent no labs yet")
+		return
 	}
-	for _, sent_lab := range stud.Labs {
-		report.students[0].labs[sent_lab.Number - int64(min_lab)] = InProgress
+	report := StudentsMarks{
+		students:        []StudentReport{b.reportRow(stud, min_lab, max_lab)},
+		total_lab_count: max_lab,
+		scored:          b.cfg.Scored(),
 	}
 	utils.RespondEphemeral(resp, createMDTable(report, min_lab))
 }
@@ -220,6 +293,8 @@ func (b *Bot) myLabs(resp http.ResponseWriter, req *http.Request) {
 type StudentsMarks struct {
 	students        []StudentReport
 	total_lab_count int
+	// scored is whether labs are worth points, the report totals them then
+	scored bool
 }
 
 type LabState int
@@ -228,12 +303,29 @@ const (
 	NotReady = iota
 	InProgress
 	Done
+	ChangesRequested
 )
 
+func openLabState(lab *database.Submission) LabState {
+	if lab.State == database.StateChangesRequested {
+		return ChangesRequested
+	}
+	return InProgress
+}
+
+// labCell is a lab of a student in the report, late is the lab's Lateness
+// and points its score after penalties, if it has one
+type labCell struct {
+	state  LabState
+	late   string
+	points *int64
+}
+
 type StudentReport struct {
-	name string
-	tag  string
-	labs []LabState
+	name  string
+	tag   string
+	labs  []labCell
+	total int64
 }
 
 func (r *StudentsMarks) Len() int {
@@ -248,6 +340,63 @@ func (r *StudentsMarks) Swap(i, j int) {
 	r.students[i], r.students[j] = r.students[j], r.students[i]
 }
 
+// labRange is the lowest and the highest number of the students' open and
+// approved labs, false if they have none
+func labRange(students []database.Student) (int, int, bool) {
+	min_lab, max_lab, ok := 0, 0, false
+	for _, stud := range students {
+		for _, lab := range append(stud.Submissions.Open(), stud.Submissions.Approved()...) {
+			if !ok || int(lab.Number) < min_lab {
+				min_lab = int(lab.Number)
+			}
+			if !ok || int(lab.Number) > max_lab {
+				max_lab = int(lab.Number)
+			}
+			ok = true
+		}
+	}
+	return min_lab, max_lab, ok
+}
+
+// reportRow is the student's row of the report with labs from min_lab to
+// max_lab
+func (b *Bot) reportRow(stud *database.Student, min_lab, max_lab int) StudentReport {
+	var row StudentReport
+	row.labs = make([]labCell, max_lab+1-min_lab)
+	if stud.RealName == nil {
+		user, err := b.client.GetUsersByIds(context.Background(), []string{stud.MmstID})
+		if err == nil && len(user) > 0 && user[0].GetFullName() != "" {
+			row.name = user[0].GetFullName()
+		} else {
+			row.name = stud.Tag
+		}
+	} else {
+		row.name = *stud.RealName
+	}
+	row.tag = fmt.Sprintf("@%s", stud.Tag)
+	for _, done_lab := range stud.Submissions.Approved() {
+		i := done_lab.Number - int64(min_lab)
+		cell := labCell{state: Done, late: done_lab.Lateness}
+		if points, ok := b.points(done_lab); ok {
+			// a retaken lab counts with its best attempt
+			if row.labs[i].points != nil && *row.labs[i].points >= points {
+				continue
+			}
+			cell.points = &points
+		}
+		row.labs[i] = cell
+	}
+	for _, cell := range row.labs {
+		if cell.points != nil {
+			row.total += *cell.points
+		}
+	}
+	for _, sent_lab := range stud.Submissions.Open() {
+		row.labs[sent_lab.Number-int64(min_lab)] = labCell{state: openLabState(sent_lab), late: sent_lab.Lateness}
+	}
+	return row
+}
+
 func (b *Bot) labs(resp http.ResponseWriter, req *http.Request) {
 	resp.Header().Add("Content-Type", "application/json")
 	err := req.ParseForm()
@@ -257,13 +406,13 @@ func (b *Bot) labs(resp http.ResponseWriter, req *http.Request) {
 		log.Println("Something went wrong with parsing the url: ", err.Error())
 		return
 	}
-	if req.Form.Get("token") != config.IntegrationTokens.Labs {
+	if req.Form.Get("token") != b.cfg.Labs {
 		resp.WriteHeader(403)
 		resp.Write([]byte("Wrong token secret"))
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	isMentor, err := database.DB.CheckMentor(ctx, &database.Mentor{
+	isMentor, err := b.store.CheckMentor(ctx, &database.Mentor{
 		MmstID: req.Form.Get("user_id"),
 		Tag:    req.Form.Get("user_name"),
 	})
@@ -271,7 +420,7 @@ func (b *Bot) labs(resp http.ResponseWriter, req *http.Request) {
 		b.myLabs(resp, req)
 		return
 	}
-	isAdmin, err := database.DB.CheckAdmin(ctx, &database.Admin{
+	isAdmin, err := b.store.CheckAdmin(ctx, &database.Admin{
 		MmstID: req.Form.Get("user_id"),
 		Tag:    req.Form.Get("user_name"),
 	})
@@ -279,68 +428,35 @@ func (b *Bot) labs(resp http.ResponseWriter, req *http.Request) {
 		b.myLabs(resp, req)
 		return
 	}
-	studArray, err := database.DB.GetStudents(ctx)
+	studArray, err := b.store.GetStudents(ctx)
 	if err != nil {
 		resp.WriteHeader(500)
 		resp.Write([]byte("Unable to parse form"))
 		log.Println("Something went wrong with parsing the url: ", err.Error())
 		return
 	}
-	var report StudentsMarks
-	report.students = make([]StudentReport, len(studArray))
-	// used as max_lab
-	report.total_lab_count = 0
-	min_lab := 1024
-	for _, stud := range studArray {
-		for _, lab := range stud.Labs {
-			if lab.Number > int64(report.total_lab_count) {
-				report.total_lab_count = int(lab.Number)
-			}
-			if lab.Number < int64(min_lab) {
-				min_lab = int(lab.Number)
-			}
-		}
-		for _, lab := range stud.DoneLabs {
-			if lab.Number > int64(report.total_lab_count) {
-				report.total_lab_count = int(lab.Number)
-			}
-			if lab.Number < int64(min_lab) {
-				min_lab = int(lab.Number)
-			}
-		}
+	min_lab, max_lab, ok := labRange(studArray)
+	if !ok {
+		utils.RespondEphemeral(resp, "No labs yet")
+		return
 	}
-	for i, v := range studArray {
-		report.students[i].labs = make([]LabState, report.total_lab_count + 1 - min_lab)
-		for j := range report.students[i].labs {
-			report.students[i].labs[j] = NotReady
-		}
-		if v.RealName == nil {
-			user, _, err := b.client.GetUsersByIds(context.Background(), []string{v.MmstID})
-			if err != nil && len(user) > 0 {
-				report.students[i].name = user[0].GetFullName()
-			} else {
-				report.students[i].name = v.Tag
-			}
-		} else {
-			report.students[i].name = *v.RealName
-		}
-		report.students[i].tag = fmt.Sprintf("@%s", v.Tag)
-		for _, done_lab := range studArray[i].DoneLabs {
-			report.students[i].labs[done_lab.Number - int64(min_lab)] = Done
-		}
-		for _, sent_lab := range studArray[i].Labs {
-			report.students[i].labs[sent_lab.Number - int64(min_lab)] = InProgress
-		}
+	report := StudentsMarks{
+		students:        make([]StudentReport, len(studArray)),
+		total_lab_count: max_lab,
+		scored:          b.cfg.Scored(),
+	}
+	for i := range studArray {
+		report.students[i] = b.reportRow(&studArray[i], min_lab, max_lab)
 	}
 	sort.Sort(&report)
 	utils.RespondEphemeral(resp, createMDTable(report, min_lab))
 	if req.Form.Get("text") == "export" {
-		channel, _, err := b.client.CreateDirectChannel(context.Background(), b.user.Id, req.Form.Get("user_id"))
+		channel, err := b.client.CreateDirectChannel(context.Background(), b.user.Id, req.Form.Get("user_id"))
 		if err != nil {
 			utils.RespondEphemeral(resp, "Unable to export!")
 			return
 		}
-		file, _, err := b.client.UploadFile(context.Background(), makeCSV(report, min_lab), channel.Id, "report.csv")
+		file, err := b.client.UploadFile(context.Background(), makeCSV(report, min_lab), channel.Id, "report.csv")
 		if err != nil || len(file.FileInfos) == 0 {
 			utils.RespondEphemeral(resp, "Unable to export!")
 			return
@@ -349,7 +465,7 @@ func (b *Bot) labs(resp http.ResponseWriter, req *http.Request) {
 			ChannelId: channel.Id,
 			FileIds:   []string{file.FileInfos[0].Id},
 		}
-		_, _, err = b.client.CreatePost(context.Background(), &post)
+		_, err = b.client.CreatePost(context.Background(), &post)
 		if err != nil {
 			utils.RespondEphemeral(resp, "Unable to export!")
 			return
@@ -366,20 +482,20 @@ func (b *Bot) mentorLabs(resp http.ResponseWriter, req *http.Request) {
 		log.Println("Something went wrong with parsing the url: ", err.Error())
 		return
 	}
-	if req.Form.Get("token") != config.IntegrationTokens.MentorLabs {
+	if req.Form.Get("token") != b.cfg.MentorLabs {
 		resp.WriteHeader(403)
 		resp.Write([]byte("Wrong token secret"))
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	isAdmin, err := database.DB.CheckAdmin(ctx, &database.Admin{
+	isAdmin, err := b.store.CheckAdmin(ctx, &database.Admin{
 		MmstID: req.Form.Get("user_id"),
 		Tag:    req.Form.Get("user_name"),
 	})
 	if err != nil || !isAdmin {
 		return
 	}
-	mentor, err := database.DB.GetMentorByTag(ctx, req.Form.Get("text"))
+	mentor, err := b.store.GetMentorByTag(ctx, req.Form.Get("text"))
 	if err != nil {
 		resp.WriteHeader(500)
 		resp.Write([]byte("Unable to find mentor"))
@@ -387,11 +503,11 @@ func (b *Bot) mentorLabs(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 	stringBuffer := "Undone labs\n"
-	for _, v := range mentor.Labs {
+	for _, v := range mentor.Submissions.Open() {
 		stringBuffer += v.Url + "\n"
 	}
 	stringBuffer += "Done labs\n"
-	for _, v := range mentor.DoneLabs {
+	for _, v := range mentor.Submissions.Approved() {
 		stringBuffer += v.Url + "\n"
 	}
 	utils.RespondEphemeral(resp, stringBuffer)
@@ -400,32 +516,41 @@ func (b *Bot) mentorLabs(resp http.ResponseWriter, req *http.Request) {
 func createMDTable(table StudentsMarks, min_lab int) string {
 	var markdown string
 	// HEADER
-	markdown += "Name | Tag | "
-	for i := min_lab; i < table.total_lab_count; i++ {
-		markdown += fmt.Sprint(i)
-		markdown += " | "
+	markdown += "Name | Tag"
+	columns := 2
+	for i := min_lab; i <= table.total_lab_count; i++ {
+		markdown += fmt.Sprintf(" | %d", i)
+		columns++
 	}
-	markdown += fmt.Sprintf("%d\n", table.total_lab_count)
-	for i := 0; i < table.total_lab_count; i++ {
-		markdown += "---"
-		markdown += " | "
+	if table.scored {
+		markdown += " | Total"
+		columns++
 	}
-	markdown += "---\n"
+	markdown += "\n---" + strings.Repeat(" | ---", columns-1) + "\n"
 	// BODY
 	for _, row := range table.students {
 		markdown += fmt.Sprintf("%s | %s | ", row.name, row.tag)
 		for i, column := range row.labs {
-			switch column {
+			switch column.state {
 			case NotReady:
 			case InProgress:
 				markdown += "🔄"
 			case Done:
 				markdown += "✅"
+			case ChangesRequested:
+				markdown += "✏️"
+			}
+			markdown += lateMarks[column.late]
+			if column.points != nil {
+				markdown += fmt.Sprintf(" %d", *column.points)
 			}
 			if i != len(row.labs)-1 {
 				markdown += " | "
 			}
 		}
+		if table.scored {
+			markdown += fmt.Sprintf(" | %d", row.total)
+		}
 		markdown += "\n"
 	}
 	return markdown
@@ -435,26 +560,50 @@ func makeCSV(table StudentsMarks, min_lab int) []byte {
 	var csv string
 	// HEADER
 	csv += "Name,Tag,"
-	for i := min_lab; i < table.total_lab_count; i++ {
+	for i := min_lab; i <= table.total_lab_count; i++ {
 		csv += fmt.Sprint(i)
 		csv += ","
 	}
+	csv += "Late,Overdue"
+	if table.scored {
+		for i := min_lab; i <= table.total_lab_count; i++ {
+			csv += fmt.Sprintf(",Score %d", i)
+		}
+		csv += ",Total"
+	}
 	csv += "\n"
 	// BODY
 	for _, row := range table.students {
 		csv += fmt.Sprintf("%s,%s,", row.name, row.tag)
-		for i, column := range row.labs {
-			switch column {
+		late, overdue := 0, 0
+		for _, column := range row.labs {
+			switch column.state {
 			case NotReady:
 				csv += "0"
 			case InProgress:
 				csv += "1"
 			case Done:
 				csv += "2"
+			case ChangesRequested:
+				csv += "3"
 			}
-			if i != len(row.labs)-1 {
+			switch column.late {
+			case database.Late:
+				late++
+			case database.Overdue:
+				overdue++
+			}
+			csv += ","
+		}
+		csv += fmt.Sprintf("%d,%d", late, overdue)
+		if table.scored {
+			for _, column := range row.labs {
 				csv += ","
+				if column.points != nil {
+					csv += fmt.Sprint(*column.points)
+				}
 			}
+			csv += fmt.Sprintf(",%d", row.total)
 		}
 		csv += "\n"
 	}
@@ -470,7 +619,7 @@ func (b *Bot) setStudName(resp http.ResponseWriter, req *http.Request) {
 		log.Println("Something went wrong with parsing the url: ", err.Error())
 		return
 	}
-	if req.Form.Get("token") != config.IntegrationTokens.SetName {
+	if req.Form.Get("token") != b.cfg.SetName {
 		resp.WriteHeader(403)
 		resp.Write([]byte("Wrong token secret"))
 	}
@@ -481,14 +630,14 @@ func (b *Bot) setStudName(resp http.ResponseWriter, req *http.Request) {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if ok, err := database.DB.CheckMentor(ctx, &database.Mentor{
+	if ok, err := b.store.CheckMentor(ctx, &database.Mentor{
 		MmstID: req.Form.Get("user_id"),
 		Tag:    req.Form.Get("user_name"),
 	}); err != nil || !ok {
 		utils.RespondEphemeral(resp, "You have no permission!")
 		return
 	}
-	stud, err := database.DB.GetStudentByTag(ctx, args[0])
+	stud, err := b.store.GetStudentByTag(ctx, args[0])
 	if err != nil {
 		log.Printf("Something went wrong at setting stud name, db.GetStudentByTag: %s", err)
 		return
@@ -498,7 +647,7 @@ func (b *Bot) setStudName(resp http.ResponseWriter, req *http.Request) {
 		studName += " " + args[i]
 	}
 	stud.RealName = &studName
-	err = database.DB.UpdateStudent(ctx, stud)
+	err = b.store.UpdateStudent(ctx, stud)
 	if err != nil {
 		log.Printf("Something went wrong at setting stud name, db.UpdateStudent: %s", err)
 		return
@@ -506,14 +655,90 @@ func (b *Bot) setStudName(resp http.ResponseWriter, req *http.Request) {
 	utils.RespondEphemeral(resp, "Done!")
 }
 
-func (b *Bot) SetupWebHooks() {
-	http.HandleFunc("/checkme", b.checkme)
-	http.HandleFunc("/addmentor", b.addmentor)
-	http.HandleFunc("/removementor", b.removementor)
-	http.HandleFunc("/actions", b.dispatchActions)
-	http.HandleFunc("/labs", b.labs)
-	http.HandleFunc("/setstudname", b.setStudName)
-	http.HandleFunc("/mentorlabs", b.mentorLabs)
-	http.HandleFunc("/ruok", b.selfCheck)
-	go http.ListenAndServe("0.0.0.0:5000", nil)
+func (b *Bot) pairing(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", "application/json")
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to parse form"))
+		log.Println("Something went wrong with parsing the url: ", err.Error())
+		return
+	}
+	if req.Form.Get("token") != b.cfg.Pairing {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong token secret"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if ok, err := b.store.CheckAdmin(ctx, &database.Admin{
+		MmstID: req.Form.Get("user_id"),
+		Tag:    req.Form.Get("user_name"),
+	}); err != nil || !ok {
+		utils.RespondEphemeral(resp, "You have no permission!")
+		return
+	}
+	args := strings.Fields(req.Form.Get("text"))
+	if len(args) < 1 {
+		utils.RespondEphemeral(resp, "Must supply student Tag, optionally followed by mentor Tag or \"none\"!")
+		return
+	}
+	stud, err := b.store.GetStudentByTag(ctx, strings.TrimPrefix(args[0], "@"))
+	if err != nil {
+		utils.RespondEphemeral(resp, "No such student!")
+		return
+	}
+	if len(args) == 1 {
+		if stud.MentorID == nil {
+			utils.RespondEphemeral(resp, fmt.Sprintf("@%s has no primary mentor yet", stud.Tag))
+			return
+		}
+		mentor, err := b.store.GetMentorById(ctx, *stud.MentorID)
+		if err != nil {
+			utils.RespondEphemeral(resp, fmt.Sprintf("@%s is paired with a mentor who is gone", stud.Tag))
+			return
+		}
+		utils.RespondEphemeral(resp, fmt.Sprintf("@%s is paired with @%s", stud.Tag, mentor.Tag))
+		return
+	}
+	var mentor *database.Mentor
+	if args[1] != "none" {
+		mentor, err = b.store.GetMentorByTag(ctx, strings.TrimPrefix(args[1], "@"))
+		if err != nil {
+			utils.RespondEphemeral(resp, "No such mentor!")
+			return
+		}
+	}
+	err = b.store.SetStudentMentor(ctx, stud, mentor)
+	if err != nil {
+		log.Printf("Something went wrong at pairing, db.SetStudentMentor: %s", err)
+		utils.RespondEphemeral(resp, "Unable to pair!")
+		return
+	}
+	utils.RespondEphemeral(resp, "Done!")
+}
+
+func (b *Bot) setupWebHooks() {
+	b.mux.HandleFunc("/checkme", b.checkme)
+	b.mux.HandleFunc("/addmentor", b.addmentor)
+	b.mux.HandleFunc("/removementor", b.removementor)
+	b.mux.HandleFunc("/actions", b.dispatchActions)
+	b.mux.HandleFunc("/dialogs", b.dispatchDialogs)
+	b.mux.HandleFunc("/labs", b.labs)
+	b.mux.HandleFunc("/setstudname", b.setStudName)
+	b.mux.HandleFunc("/mentorlabs", b.mentorLabs)
+	b.mux.HandleFunc("/pairing", b.pairing)
+	b.mux.HandleFunc("/recalcload", b.recalcLoad)
+	b.mux.HandleFunc("/reassign", b.reassignCmd)
+	b.mux.HandleFunc("/away", b.away)
+	b.mux.HandleFunc("/capacity", b.capacity)
+	b.mux.HandleFunc("/skills", b.skills)
+	b.mux.HandleFunc("/linkgithub", b.linkGitHub)
+	b.mux.HandleFunc("/deadline", b.deadline)
+	b.mux.HandleFunc("/withdraw", b.withdraw)
+	b.mux.HandleFunc("/resubmit", b.resubmitCmd)
+	b.mux.HandleFunc("/queue", b.reviewQueue)
+	b.mux.HandleFunc("/webhooks/github", b.githubWebhook)
+	b.mux.HandleFunc("/webhooks/gitlab", b.gitlabWebhook)
+	b.mux.HandleFunc("/ruok", b.selfCheck)
 }
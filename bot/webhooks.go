@@ -0,0 +1,290 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/forge"
+	"github.com/zinstack625/mostful_manager/utils"
+)
+
+// maxWebhookBody is as much as GitHub ever sends
+const maxWebhookBody = 25 << 20
+
+func (b *Bot) githubWebhook(resp http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxWebhookBody))
+	if err != nil {
+		resp.WriteHeader(400)
+		return
+	}
+	if !forge.VerifyGitHub(b.cfg.GitHubWebhookSecret, body, req.Header.Get("X-Hub-Signature-256")) {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong signature"))
+		return
+	}
+	ev, err := forge.ParseGitHubEvent(req.Header.Get("X-GitHub-Event"), body)
+	b.serveEvent(resp, ev, err)
+}
+
+func (b *Bot) gitlabWebhook(resp http.ResponseWriter, req *http.Request) {
+	if !forge.VerifyGitLab(b.cfg.GitLabWebhookToken, req.Header.Get("X-Gitlab-Token")) {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong token secret"))
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxWebhookBody))
+	if err != nil {
+		resp.WriteHeader(400)
+		return
+	}
+	ev, err := forge.ParseGitLabEvent(body)
+	b.serveEvent(resp, ev, err)
+}
+
+// serveEvent answers the forge once the event is handled, parsing errors
+// included
+func (b *Bot) serveEvent(resp http.ResponseWriter, ev *forge.Event, err error) {
+	if errors.Is(err, forge.ErrIgnoredEvent) {
+		resp.WriteHeader(204)
+		return
+	}
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("Unable to parse event"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := b.handleEvent(ctx, ev); err != nil {
+		log.Printf("Something went wrong at handling %s event of %s: %s", ev.Kind, ev.URL, err)
+		resp.WriteHeader(500)
+		return
+	}
+	resp.WriteHeader(204)
+}
+
+// handleEvent brings the open labs of the event's pull request up to date
+func (b *Bot) handleEvent(ctx context.Context, ev *forge.Event) error {
+	if ev.Kind == forge.CIChanged {
+		return b.ciChanged(ctx, ev)
+	}
+	pr, err := b.forges.Parse(ev.URL)
+	if err != nil {
+		// not a pull request any lab could come from
+		return nil
+	}
+	subs, err := b.store.GetSubmissionsByUrl(ctx, pr.Canonical())
+	if err != nil {
+		return err
+	}
+	for i := range subs {
+		lab := &subs[i]
+		if !lab.Open() {
+			continue
+		}
+		switch ev.Kind {
+		case forge.PullPushed:
+			err = b.labPushed(ctx, lab, ev)
+		case forge.PullClosed:
+			err = b.labClosed(ctx, lab)
+		case forge.PullMerged:
+			err = b.labMerged(ctx, pr, lab, ev)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// labPushed lets the mentor know there's something new to look at
+func (b *Bot) labPushed(ctx context.Context, lab *database.Submission, ev *forge.Event) error {
+	if err := b.store.PushLab(ctx, lab, ev.HeadSHA); err != nil {
+		return err
+	}
+	if lab.MentorID == 0 {
+		return nil
+	}
+	mentor, err := b.store.GetMentorById(ctx, lab.MentorID)
+	if err != nil {
+		return err
+	}
+	go utils.SendDM(b.user.Id, mentor.MmstID, fmt.Sprintf("%s got new commits, head %.7s", lab.Url, ev.HeadSHA), nil, b.client)
+	return nil
+}
+
+// labClosed withdraws the lab of a pull request closed without merging
+func (b *Bot) labClosed(ctx context.Context, lab *database.Submission) error {
+	if err := b.store.WithdrawLab(ctx, lab, "", "pull request closed"); err != nil {
+		return err
+	}
+	b.strikeLabPost(ctx, lab, "🚫 Withdrawn, the pull request was closed")
+	if stud, err := b.store.GetStudentById(ctx, lab.StudentID); err == nil {
+		go utils.SendDM(b.user.Id, stud.MmstID, fmt.Sprintf("Lab %s was withdrawn since its pull request was closed, /checkme it again once it's reopened", lab.Url), nil, b.client)
+	}
+	go b.assignQueued()
+	return nil
+}
+
+// labMerged approves the lab when its own mentor merged the GitHub pull
+// request and approve_on_merge is on, otherwise the mentor is told about the
+// merge
+func (b *Bot) labMerged(ctx context.Context, pr *forge.PullRequest, lab *database.Submission, ev *forge.Event) error {
+	if lab.MentorID == 0 {
+		return nil
+	}
+	mentor, err := b.store.GetMentorById(ctx, lab.MentorID)
+	if err != nil {
+		return err
+	}
+	if !b.cfg.ApproveOnMerge || lab.State == database.StateAwaitingCI || !b.mergedBy(ctx, mentor, pr.Kind, ev.Actor) {
+		go utils.SendDM(b.user.Id, mentor.MmstID, fmt.Sprintf("%s was merged by %s", lab.Url, ev.Actor), nil, b.client)
+		return nil
+	}
+	// labs worth points aren't approved without them
+	if b.maxScore(lab) > 0 {
+		go utils.SendDM(b.user.Id, mentor.MmstID, fmt.Sprintf("%s was merged, score it to approve it", lab.Url), nil, b.client)
+		return nil
+	}
+	if err := b.store.FinishLab(ctx, lab, mentor.MmstID); err != nil {
+		return err
+	}
+	b.refreshLabPost(ctx, lab, "✅ Approved by merging the pull request")
+	if stud, err := b.store.GetStudentById(ctx, lab.StudentID); err == nil {
+		go utils.SendDM(b.user.Id, stud.MmstID, fmt.Sprintf("Lab %s approved!", lab.Url), nil, b.client)
+	}
+	go b.assignQueued()
+	return nil
+}
+
+// ciChanged lets the labs waiting for CI of the commit go on once it passes,
+// and withdraws them once it fails
+func (b *Bot) ciChanged(ctx context.Context, ev *forge.Event) error {
+	if b.pulls == nil {
+		return nil
+	}
+	labs, err := b.store.GetParkedLabs(ctx, ev.HeadSHA)
+	if err != nil {
+		return err
+	}
+	// labs without a mentor go through the queue, whoever is left in it
+	// learns their place
+	queued := map[*database.Submission]string{}
+	for i := range labs {
+		lab := &labs[i]
+		pr, err := b.forges.Parse(lab.Url)
+		if err != nil {
+			continue
+		}
+		state, err := b.pulls.CIStatus(ctx, pr, lab.HeadSHA)
+		if err != nil {
+			return err
+		}
+		if state == forge.CIPending {
+			continue
+		}
+		lab.CIState = string(state)
+		if err := b.store.SetPullInfo(ctx, lab); err != nil {
+			return err
+		}
+		stud, err := b.store.GetStudentById(ctx, lab.StudentID)
+		if err != nil {
+			return err
+		}
+		if state == forge.CIFailure {
+			if err := b.store.WithdrawLab(ctx, lab, "", "CI failed"); err != nil {
+				return err
+			}
+			go utils.SendDM(b.user.Id, stud.MmstID, fmt.Sprintf("CI fails on %.7s, lab %s is withdrawn. Fix it and /checkme again", lab.HeadSHA, lab.Url), nil, b.client)
+			continue
+		}
+		if err := b.store.PassCI(ctx, lab); err != nil {
+			return err
+		}
+		if lab.State == database.StateQueued {
+			queued[lab] = stud.MmstID
+			continue
+		}
+		mentor, err := b.store.GetMentorById(ctx, lab.MentorID)
+		if err != nil {
+			return err
+		}
+		go utils.SendDM(b.user.Id, stud.MmstID, fmt.Sprintf("CI passed, lab %s sent back to @%s", lab.Url, mentor.Tag), nil, b.client)
+		go b.sendLab(mentor, lab, fmt.Sprintf("@%s fixed: %s", stud.Tag, lab.Url))
+	}
+	if len(queued) == 0 {
+		return nil
+	}
+	b.assignQueued()
+	for lab, studID := range queued {
+		lab, err := b.store.GetSubmission(ctx, lab.ID)
+		if err != nil || lab.State != database.StateQueued {
+			continue
+		}
+		pos, err := b.store.QueuePosition(ctx, lab)
+		if err != nil {
+			log.Printf("Something went wrong at queueing lab, db.QueuePosition: %s", err)
+		}
+		go utils.SendDM(b.user.Id, studID, fmt.Sprintf("CI passed, but all mentors are busy, lab %s is #%d in the queue. You'll get a message once it's assigned", lab.Url, pos), nil, b.client)
+	}
+	return nil
+}
+
+// mergedBy reports whether login is the mentor's verified account on the
+// forge. Only GitHub accounts can be linked, so merges anywhere else never
+// count as the mentor's.
+func (b *Bot) mergedBy(ctx context.Context, mentor *database.Mentor, kind forge.Kind, login string) bool {
+	if kind != forge.GitHub {
+		return false
+	}
+	acc, err := b.store.GetForgeAccount(ctx, mentor.MmstID, string(kind))
+	if err != nil {
+		return false
+	}
+	return acc.Verified && strings.EqualFold(acc.Login, login)
+}
+
+// strikeLabPost strikes the mentor's post of the lab out, drops its buttons
+// and says why
+func (b *Bot) strikeLabPost(ctx context.Context, lab *database.Submission, note string) {
+	if lab.PostID == "" {
+		return
+	}
+	op, err := b.client.GetPost(ctx, lab.PostID)
+	if err != nil {
+		log.Printf("Unable to get post of lab %d: %s", lab.ID, err)
+		return
+	}
+	op.Message = fmt.Sprintf("~~%s~~\n%s", op.Message, note)
+	op.DelProp("attachments")
+	if _, err := b.client.UpdatePost(ctx, op); err != nil {
+		log.Printf("Unable to update post of lab %d: %s", lab.ID, err)
+	}
+}
+
+// refreshLabPost gives the mentor's post of the lab the buttons of its state
+// and appends the note, if any
+func (b *Bot) refreshLabPost(ctx context.Context, lab *database.Submission, note string) {
+	if lab.PostID == "" {
+		return
+	}
+	op, err := b.client.GetPost(ctx, lab.PostID)
+	if err != nil {
+		log.Printf("Unable to get post of lab %d: %s", lab.ID, err)
+		return
+	}
+	if note != "" {
+		op.Message += "\n" + note
+	}
+	op.AddProp("attachments", b.labActions(lab))
+	if _, err := b.client.UpdatePost(ctx, op); err != nil {
+		log.Printf("Unable to update post of lab %d: %s", lab.ID, err)
+	}
+}
@@ -0,0 +1,248 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/utils"
+)
+
+// studentLab finds the student's lab by its URL, or by its number, the
+// latest one sent with it then. The message says what's wrong if there's
+// no such lab.
+func (b *Bot) studentLab(ctx context.Context, tag, key string) (*database.Submission, string) {
+	stud, err := b.store.GetStudentByTag(ctx, tag)
+	if err != nil {
+		return nil, "You have sent no labs yet"
+	}
+	var found *database.Submission
+	if number, err := strconv.ParseInt(key, 10, 64); err == nil {
+		for _, lab := range stud.Submissions {
+			if lab.Number == number && (found == nil || lab.SubmittedAt.After(found.SubmittedAt)) {
+				found = lab
+			}
+		}
+	} else if pr, err := b.forges.Parse(key); err == nil {
+		url := pr.Canonical()
+		for _, lab := range stud.Submissions {
+			if lab.Url == url {
+				found = lab
+			}
+		}
+	}
+	if found == nil {
+		return nil, fmt.Sprintf("You haven't sent lab %s", key)
+	}
+	return found, ""
+}
+
+// withdraw lets a student call their lab off:
+//
+//	/withdraw 3
+//	/withdraw https://github.com/owner/repo/pull/1
+func (b *Bot) withdraw(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", "application/json")
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to parse form"))
+		log.Println("Something went wrong with parsing the url: ", err.Error())
+		return
+	}
+	if req.Form.Get("token") != b.cfg.Withdraw {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong token secret"))
+		return
+	}
+	args := strings.Fields(req.Form.Get("text"))
+	if len(args) != 1 {
+		utils.RespondEphemeral(resp, "Usage: /withdraw <lab number or url>")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	lab, msg := b.studentLab(ctx, req.Form.Get("user_name"), args[0])
+	if msg != "" {
+		utils.RespondEphemeral(resp, msg)
+		return
+	}
+	switch lab.State {
+	case database.StateApproved:
+		utils.RespondEphemeral(resp, fmt.Sprintf("Lab %s is already approved", lab.Url))
+		return
+	case database.StateWithdrawn:
+		utils.RespondEphemeral(resp, fmt.Sprintf("Lab %s is already withdrawn", lab.Url))
+		return
+	}
+	err = b.store.WithdrawLab(ctx, lab, req.Form.Get("user_id"), "")
+	if err != nil {
+		log.Printf("Something went wrong at withdrawing, db.WithdrawLab: %s", err)
+		utils.RespondEphemeral(resp, "Unable to withdraw the lab, try again later")
+		return
+	}
+	b.strikeLabPost(ctx, lab, "🚫 Withdrawn by the student")
+	go b.assignQueued()
+	utils.RespondEphemeral(resp, fmt.Sprintf("Lab %s withdrawn, /checkme or /resubmit it once it's ready", lab.Url))
+}
+
+// resubmitCmd puts another pull request in place of a lab, it keeps its
+// mentor and history:
+//
+//	/resubmit 3 https://github.com/owner/repo/pull/2
+func (b *Bot) resubmitCmd(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Add("Content-Type", "application/json")
+	err := req.ParseForm()
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte("Unable to parse form"))
+		log.Println("Something went wrong with parsing the url: ", err.Error())
+		return
+	}
+	if req.Form.Get("token") != b.cfg.Resubmit {
+		resp.WriteHeader(403)
+		resp.Write([]byte("Wrong token secret"))
+		return
+	}
+	args := strings.Fields(req.Form.Get("text"))
+	if len(args) != 2 {
+		utils.RespondEphemeral(resp, "Usage: /resubmit <lab number or url> <new url>")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	lab, msg := b.studentLab(ctx, req.Form.Get("user_name"), args[0])
+	if msg != "" {
+		utils.RespondEphemeral(resp, msg)
+		return
+	}
+	if lab.State == database.StateApproved {
+		utils.RespondEphemeral(resp, fmt.Sprintf("Lab %s is already approved", lab.Url))
+		return
+	}
+	pr, err := b.forges.Parse(args[1])
+	if err != nil {
+		utils.RespondEphemeral(resp, b.cfg.LabHint())
+		return
+	}
+	match, ok := b.cfg.MatchLab(pr.Canonical())
+	if !ok {
+		utils.RespondEphemeral(resp, b.cfg.LabHint())
+		return
+	}
+	if !match.Numbered {
+		match.Number = pr.LabNumber()
+	}
+	if match.Course != lab.Course || match.Number != lab.Number {
+		utils.RespondEphemeral(resp, fmt.Sprintf("%s is not lab %d, /checkme it instead", pr.Canonical(), lab.Number))
+		return
+	}
+	moved := database.Submission{
+		Url:       pr.Canonical(),
+		StudentID: lab.StudentID,
+		Number:    lab.Number,
+		Course:    lab.Course,
+	}
+	if msg := b.checkPull(ctx, pr, &moved); msg != "" {
+		utils.RespondEphemeral(resp, msg)
+		return
+	}
+	if msg := b.checkAuthor(ctx, req.Form.Get("user_id"), pr, &moved); msg != "" {
+		utils.RespondEphemeral(resp, msg)
+		return
+	}
+	if msg := b.checkCI(ctx, pr, &moved); msg != "" {
+		utils.RespondEphemeral(resp, msg)
+		return
+	}
+	student := &database.Student{
+		ID:     lab.StudentID,
+		MmstID: req.Form.Get("user_id"),
+		Tag:    req.Form.Get("user_name"),
+	}
+	b.replaceLab(resp, student, lab, &moved)
+}
+
+// replaceLab puts the moved pull request, already checked, in place of the
+// lab. Labs that are withdrawn, sent back for changes or waiting for CI go
+// back to review, open ones just get the new link.
+func (b *Bot) replaceLab(resp http.ResponseWriter, student *database.Student, lab, moved *database.Submission) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pending := moved.State == database.StateAwaitingCI
+	backToReview := lab.State == database.StateChangesRequested || lab.State == database.StateWithdrawn || lab.State == database.StateAwaitingCI
+	if pending && !backToReview {
+		utils.RespondEphemeral(resp, fmt.Sprintf("CI is still running on %.7s, /resubmit once it passes", moved.HeadSHA))
+		return
+	}
+	if backToReview {
+		if _, msg := b.checkDuplicate(ctx, lab); msg != "" {
+			utils.RespondEphemeral(resp, msg)
+			return
+		}
+		if msg := b.checkReturn(ctx, lab); msg != "" {
+			utils.RespondEphemeral(resp, msg)
+			return
+		}
+	}
+	prev := *lab
+	lab.PullTitle, lab.PullAuthor, lab.PullState = moved.PullTitle, moved.PullAuthor, moved.PullState
+	lab.HeadSHA, lab.ChangedFiles, lab.CIState = moved.HeadSHA, moved.ChangedFiles, moved.CIState
+	err := b.store.MoveLab(ctx, lab, moved.Url, student.MmstID)
+	if errors.Is(err, database.ErrLabExists) {
+		utils.RespondEphemeral(resp, fmt.Sprintf("You have already sent %s", moved.Url))
+		return
+	}
+	if err != nil {
+		log.Printf("Something went wrong at moving lab %d, db.MoveLab: %s", lab.ID, err)
+		utils.RespondEphemeral(resp, "Unable to resubmit the lab, try again later")
+		return
+	}
+	if !backToReview {
+		b.relinkLabPost(ctx, lab, &prev)
+		if mentor, err := b.store.GetMentorById(ctx, lab.MentorID); err == nil {
+			go utils.SendDM(b.user.Id, mentor.MmstID, fmt.Sprintf("@%s moved %s to %s", student.Tag, prev.Url, lab.Url), nil, b.client)
+		}
+		utils.RespondEphemeral(resp, fmt.Sprintf("Lab %d is now %s", lab.Number, lab.Url))
+		return
+	}
+	// the mentor gets a post of the new link once it's back in review
+	if prev.State != database.StateWithdrawn {
+		b.strikeLabPost(ctx, lab, fmt.Sprintf("🔁 Resubmitted as %s", lab.Url))
+	}
+	switch {
+	case pending && prev.State == database.StateAwaitingCI:
+		utils.RespondEphemeral(resp, fmt.Sprintf("CI is still running on %.7s, lab %s goes on once it passes", lab.HeadSHA, lab.Url))
+	case pending:
+		b.park(resp, student, lab)
+	default:
+		b.resubmit(resp, student, lab)
+	}
+}
+
+// relinkLabPost puts the lab's new URL and pull request into the mentor's
+// post of it in place of the old ones
+func (b *Bot) relinkLabPost(ctx context.Context, lab, old *database.Submission) {
+	if lab.PostID == "" {
+		return
+	}
+	op, err := b.client.GetPost(ctx, lab.PostID)
+	if err != nil {
+		log.Printf("Unable to get post of lab %d: %s", lab.ID, err)
+		return
+	}
+	if summary := pullSummary(old); summary != "" {
+		op.Message = strings.Replace(op.Message, summary, pullSummary(lab), 1)
+	}
+	op.Message = strings.ReplaceAll(op.Message, old.Url, lab.Url) + "\n🔗 Moved from " + old.Url
+	op.AddProp("attachments", b.labActions(lab))
+	if _, err := b.client.UpdatePost(ctx, op); err != nil {
+		log.Printf("Unable to update post of lab %d: %s", lab.ID, err)
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+)
+
+type Config struct {
+	IntegrationTokens
+	Settings
+}
+
+// Load reads the config file, all keys live on its top level
+func Load(configPath string) (*Config, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	cfg := &Config{}
+	reader := bufio.NewReader(file)
+	decoder := json.NewDecoder(reader)
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.compileLabPatterns(); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.checkCourses()
+}
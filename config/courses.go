@@ -0,0 +1,113 @@
+package config
+
+import "fmt"
+
+// Course is how the labs of a course are handled. Courses are keyed by the
+// course names of lab_patterns, "" is the course of the default pattern.
+type Course struct {
+	// CI is what the pull request's CI has to say before the lab goes to a
+	// mentor, one of the CI policies below, off by default
+	CI string `json:"ci"`
+	// Duplicates is what happens to another pull request for a lab the
+	// student has already sent, one of the duplicate policies below, reject
+	// by default
+	Duplicates string `json:"duplicates"`
+	// RejectOverdue turns away labs submitted after their hard deadline
+	RejectOverdue bool `json:"reject_overdue"`
+	// MaxScore is the most points a lab is worth, MaxScores overrides it for
+	// single labs by number. Labs worth nothing are approved without a score.
+	MaxScore  int64           `json:"max_score"`
+	MaxScores map[int64]int64 `json:"max_scores"`
+	// The penalties take a percentage of the score off late labs, overdue
+	// labs, and off every time the mentor asked for changes
+	LatePenalty     int64 `json:"late_penalty"`
+	OverduePenalty  int64 `json:"overdue_penalty"`
+	RevisionPenalty int64 `json:"revision_penalty"`
+}
+
+const (
+	// CIRequired turns away labs with failing CI and holds back ones with
+	// CI still running until it passes
+	CIRequired = "required"
+	// CIAdvisory takes labs whatever their CI says, the mentor sees it
+	CIAdvisory = "advisory"
+	CIOff      = "off"
+)
+
+const (
+	// DuplicatesReject turns the pull request away while the earlier one is
+	// open or approved, only withdrawn labs can be sent anew
+	DuplicatesReject = "reject"
+	// DuplicatesResubmit puts the pull request in place of the earlier one,
+	// it goes to the same mentor as if /resubmit was used
+	DuplicatesResubmit = "resubmit"
+	// DuplicatesRetake takes the pull request as another attempt once the
+	// earlier one is approved or withdrawn
+	DuplicatesRetake = "retake"
+)
+
+// Course is the settings of the named course, the defaults if it has none
+func (s *Settings) Course(name string) Course {
+	course := s.Courses[name]
+	if course.CI == "" {
+		course.CI = CIOff
+	}
+	if course.Duplicates == "" {
+		course.Duplicates = DuplicatesReject
+	}
+	return course
+}
+
+// MaxScoreOf is the most points the lab is worth, zero if it isn't scored
+func (c Course) MaxScoreOf(number int64) int64 {
+	if max, ok := c.MaxScores[number]; ok {
+		return max
+	}
+	return c.MaxScore
+}
+
+// Points is what's left of the score after the penalties, never below zero
+func (c Course) Points(score int64, late, overdue bool, revisions int64) int64 {
+	penalty := c.RevisionPenalty * revisions
+	switch {
+	case overdue:
+		penalty += c.OverduePenalty
+	case late:
+		penalty += c.LatePenalty
+	}
+	if penalty >= 100 {
+		return 0
+	}
+	return score * (100 - penalty) / 100
+}
+
+// Scored reports whether any lab of any course is worth points
+func (s *Settings) Scored() bool {
+	for _, course := range s.Courses {
+		if course.MaxScore > 0 || len(course.MaxScores) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Settings) checkCourses() error {
+	for name, course := range s.Courses {
+		switch course.CI {
+		case "", CIRequired, CIAdvisory, CIOff:
+		default:
+			return fmt.Errorf("courses: %q: unknown ci policy %q", name, course.CI)
+		}
+		switch course.Duplicates {
+		case "", DuplicatesReject, DuplicatesResubmit, DuplicatesRetake:
+		default:
+			return fmt.Errorf("courses: %q: unknown duplicates policy %q", name, course.Duplicates)
+		}
+		for _, penalty := range []int64{course.LatePenalty, course.OverduePenalty, course.RevisionPenalty} {
+			if penalty < 0 || penalty > 100 {
+				return fmt.Errorf("courses: %q: penalties are percentages, %d isn't", name, penalty)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package config
+
+import "testing"
+
+func TestPoints(t *testing.T) {
+	course := Course{LatePenalty: 10, OverduePenalty: 50, RevisionPenalty: 20}
+	tests := []struct {
+		name      string
+		score     int64
+		late      bool
+		overdue   bool
+		revisions int64
+		want      int64
+	}{
+		{"on time", 10, false, false, 0, 10},
+		{"late", 10, true, false, 0, 9},
+		{"overdue", 10, false, true, 0, 5},
+		{"overdue is not late as well", 10, true, true, 0, 5},
+		{"revisions", 10, false, false, 2, 6},
+		{"late with a revision", 10, true, false, 1, 7},
+		{"rounds down", 7, true, false, 0, 6},
+		{"never below zero", 10, false, true, 3, 0},
+		{"nothing to lose", 0, true, false, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := course.Points(tt.score, tt.late, tt.overdue, tt.revisions); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxScoreOf(t *testing.T) {
+	course := Course{MaxScore: 10, MaxScores: map[int64]int64{3: 20, 4: 0}}
+	for number, want := range map[int64]int64{1: 10, 3: 20, 4: 0} {
+		if got := course.MaxScoreOf(number); got != want {
+			t.Errorf("lab %d: got %d, want %d", number, got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LabPattern describes the submission URLs of a course. Pattern is a regular
+// expression the whole canonical URL has to match, whatever the case of
+// either, since canonical URLs are lowercased. The lab number is taken
+// from its capturing groups named "number", the first one that matched
+// anything. Without such groups the number comes from the repository name.
+// Hint tells students what a good URL looks like when theirs matches nothing.
+type LabPattern struct {
+	Course  string `json:"course"`
+	Pattern string `json:"pattern"`
+	Hint    string `json:"hint"`
+	re      *regexp.Regexp
+}
+
+// LabMatch is what a submission URL told about the lab, Numbered is false
+// when the pattern doesn't know the lab number
+type LabMatch struct {
+	Course   string
+	Number   int64
+	Numbered bool
+}
+
+// defaultLabPatterns are used when the config has none. Test labs have no
+// number there.
+var defaultLabPatterns = mustCompile([]LabPattern{{
+	Pattern: `^https://github.com/.*/(?:(?:[0-9]{2}-lab-(?P<number>[0-9]{2})-.*)|(?:lab-test-[0-9]{1}-.*))/pull/[0-9]{1,}$`,
+	Hint:    `https://github.com/bmstu-cbeer-20**/**-lab-**-YourName/pull/1`,
+}})
+
+func (p *LabPattern) compile() error {
+	re, err := regexp.Compile("(?i)" + p.Pattern)
+	if err != nil {
+		return err
+	}
+	p.re = re
+	return nil
+}
+
+func mustCompile(patterns []LabPattern) []LabPattern {
+	for i := range patterns {
+		if err := patterns[i].compile(); err != nil {
+			panic(err)
+		}
+	}
+	return patterns
+}
+
+// match reports whether the URL is of the pattern and what lab it is
+func (p *LabPattern) match(url string) (LabMatch, bool) {
+	groups := p.re.FindStringSubmatch(url)
+	if groups == nil {
+		return LabMatch{}, false
+	}
+	res := LabMatch{Course: p.Course}
+	for i, name := range p.re.SubexpNames() {
+		if name != "number" {
+			continue
+		}
+		res.Numbered = true
+		if groups[i] != "" {
+			res.Number, _ = strconv.ParseInt(groups[i], 10, 64)
+			break
+		}
+	}
+	return res, true
+}
+
+func (s *Settings) compileLabPatterns() error {
+	for i := range s.LabPatterns {
+		if err := s.LabPatterns[i].compile(); err != nil {
+			return fmt.Errorf("lab_patterns: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Settings) labPatterns() []LabPattern {
+	if len(s.LabPatterns) == 0 {
+		return defaultLabPatterns
+	}
+	return s.LabPatterns
+}
+
+// MatchLab finds the first pattern the URL matches
+func (s *Settings) MatchLab(url string) (LabMatch, bool) {
+	for i := range s.labPatterns() {
+		if m, ok := s.labPatterns()[i].match(url); ok {
+			return m, true
+		}
+	}
+	return LabMatch{}, false
+}
+
+// LabHint is the message for a URL that matches no pattern
+func (s *Settings) LabHint() string {
+	var hints []string
+	for _, p := range s.labPatterns() {
+		if p.Hint != "" {
+			hints = append(hints, fmt.Sprintf("\"%s\"", p.Hint))
+		}
+	}
+	if len(hints) == 0 {
+		return "Does not seem like a lab we check!"
+	}
+	return "Does not seem like a lab we check! Make sure the URL is in form of " + strings.Join(hints, " or ")
+}
@@ -0,0 +1,62 @@
+package config
+
+import "testing"
+
+func TestMatchLab(t *testing.T) {
+	custom := Settings{LabPatterns: []LabPattern{
+		{Course: "os", Pattern: `^https://github.com/os-2026/(?:hw(?P<number>[0-9]+)|lab-(?P<number>[0-9]+))-.*/pull/[0-9]+$`},
+		{Course: "db", Pattern: `^https://gitlab.com/db/.*/-/merge_requests/[0-9]+$`},
+		{Course: "cbeer", Pattern: `^https://github.com/BMSTU-cbeer/.*/pull/[0-9]+$`},
+	}}
+	if err := custom.compileLabPatterns(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		settings *Settings
+		url      string
+		want     LabMatch
+		ok       bool
+	}{
+		{"default", &Settings{}, "https://github.com/bmstu-cbeer-2026/01-lab-03-name/pull/1", LabMatch{Number: 3, Numbered: true}, true},
+		{"default test lab", &Settings{}, "https://github.com/bmstu-cbeer-2026/lab-test-1-name/pull/2", LabMatch{Numbered: true}, true},
+		{"default other repo", &Settings{}, "https://github.com/bmstu-cbeer-2026/homework/pull/1", LabMatch{}, false},
+		{"default not a pull", &Settings{}, "https://github.com/bmstu-cbeer-2026/01-lab-03-name/pull/1/files", LabMatch{}, false},
+		{"first group", &custom, "https://github.com/os-2026/hw4-name/pull/1", LabMatch{Course: "os", Number: 4, Numbered: true}, true},
+		{"second group", &custom, "https://github.com/os-2026/lab-12-name/pull/1", LabMatch{Course: "os", Number: 12, Numbered: true}, true},
+		{"no group", &custom, "https://gitlab.com/db/group/repo/-/merge_requests/3", LabMatch{Course: "db"}, true},
+		{"mixed case pattern", &custom, "https://github.com/bmstu-cbeer/01-lab-02-name/pull/1", LabMatch{Course: "cbeer"}, true},
+		{"no pattern", &custom, "https://github.com/os-2025/hw4-name/pull/1", LabMatch{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.settings.MatchLab(tt.url)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("got %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCompileLabPatterns(t *testing.T) {
+	bad := Settings{LabPatterns: []LabPattern{{Pattern: `^https://(`}}}
+	if err := bad.compileLabPatterns(); err == nil {
+		t.Error("bad pattern compiled")
+	}
+}
+
+func TestLabHint(t *testing.T) {
+	tests := []struct {
+		patterns []LabPattern
+		want     string
+	}{
+		{[]LabPattern{{Pattern: "x"}}, "Does not seem like a lab we check!"},
+		{[]LabPattern{{Pattern: "x", Hint: "a"}, {Pattern: "y"}, {Pattern: "z", Hint: "b"}}, `Does not seem like a lab we check! Make sure the URL is in form of "a" or "b"`},
+	}
+	for _, tt := range tests {
+		s := Settings{LabPatterns: tt.patterns}
+		if got := s.LabHint(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -1,28 +1,22 @@
 package config
 
-import (
-	"bufio"
-	"encoding/json"
-	"os"
-)
-
-type integrationTokens struct {
+// IntegrationTokens are the secrets Mattermost sends along with slash commands
+type IntegrationTokens struct {
 	AddMentor    string `json:"add_mentor"`
 	RemoveMentor string `json:"remove_mentor"`
 	CheckMe      string `json:"check_me"`
 	Labs         string `json:"labs"`
 	SetName      string `json:"set_name"`
 	MentorLabs   string `json:"mentor_labs"`
+	Pairing      string `json:"pairing"`
+	RecalcLoad   string `json:"recalc_load"`
+	Reassign     string `json:"reassign"`
+	Away         string `json:"away"`
+	Capacity     string `json:"capacity"`
+	Skills       string `json:"skills"`
+	LinkGitHub   string `json:"link_github"`
+	Deadline     string `json:"deadline"`
+	Withdraw     string `json:"withdraw"`
+	Resubmit     string `json:"resubmit"`
+	Queue        string `json:"queue"`
 }
-
-func (i *integrationTokens) Init(configPath string) error {
-	file, err := os.Open(configPath)
-	if err != nil {
-		return nil
-	}
-	reader := bufio.NewReader(file)
-	decoder := json.NewDecoder(reader)
-	return decoder.Decode(i)
-}
-
-var IntegrationTokens integrationTokens
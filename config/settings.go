@@ -0,0 +1,48 @@
+package config
+
+import "time"
+
+type Settings struct {
+	AssignmentStrategy string `json:"assignment_strategy"`
+	LoadDecayWindow    string `json:"load_decay_window"`
+	LoadCheckInterval  string `json:"load_check_interval"`
+	// LabPatterns are the submission URLs /checkme accepts, see patterns.go
+	LabPatterns []LabPattern `json:"lab_patterns"`
+	// Courses are the settings of every course, see courses.go
+	Courses map[string]Course `json:"courses"`
+	// Forges are the self-hosted forges, host name to github, gitlab, gitea
+	// or bitbucket
+	Forges map[string]string `json:"forges"`
+	// GitHubAPI is where GitHub pull requests are looked up on submission,
+	// like https://api.github.com, empty turns it off. GitHubToken is only
+	// needed for private repositories and higher rate limits.
+	GitHubAPI   string `json:"github_api"`
+	GitHubToken string `json:"github_token"`
+	// RequireLinkedAccount turns away labs of students who haven't linked
+	// their GitHub account with /linkgithub. Those who did only get their
+	// own GitHub pull requests taken either way.
+	RequireLinkedAccount bool `json:"require_linked_account"`
+	// GitHubWebhookSecret and GitLabWebhookToken are the secrets of the
+	// pull request webhooks sent to /webhooks/github and /webhooks/gitlab,
+	// empty ones turn them off
+	GitHubWebhookSecret string `json:"github_webhook_secret"`
+	GitLabWebhookToken  string `json:"gitlab_webhook_token"`
+	// ApproveOnMerge approves labs whose pull request their mentor merged
+	ApproveOnMerge bool `json:"approve_on_merge"`
+}
+
+// durationOr parses a Go duration string, empty strings yield def
+func durationOr(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func (s *Settings) LoadDecay() (time.Duration, error) {
+	return durationOr(s.LoadDecayWindow, 0)
+}
+
+func (s *Settings) LoadCheck() (time.Duration, error) {
+	return durationOr(s.LoadCheckInterval, time.Hour)
+}
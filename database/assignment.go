@@ -0,0 +1,183 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNoMentors = errors.New("no mentors to assign the lab to")
+
+// AssignmentStrategy picks the mentor a freshly submitted lab goes to.
+// Candidates come with their Submissions loaded.
+type AssignmentStrategy interface {
+	SelectMentor(candidates []Mentor, lab *Submission) (*Mentor, error)
+}
+
+func NewAssignmentStrategy(name string) (AssignmentStrategy, error) {
+	switch name {
+	case "", "least_loaded":
+		return LeastLoaded{}, nil
+	case "round_robin":
+		return &RoundRobin{}, nil
+	case "weighted":
+		return Weighted{}, nil
+	case "sticky":
+		return Sticky{Fallback: LeastLoaded{}}, nil
+	}
+	return nil, fmt.Errorf("unknown assignment strategy %q", name)
+}
+
+// LeastLoaded picks the mentor with the lowest load, the oldest one on ties.
+type LeastLoaded struct{}
+
+func (LeastLoaded) SelectMentor(candidates []Mentor, lab *Submission) (*Mentor, error) {
+	if len(candidates) == 0 {
+		return nil, ErrNoMentors
+	}
+	best := &candidates[0]
+	for i := range candidates[1:] {
+		c := &candidates[i+1]
+		if c.Load < best.Load || (c.Load == best.Load && c.ID < best.ID) {
+			best = c
+		}
+	}
+	return best, nil
+}
+
+// RoundRobin hands labs to mentors in turn, ordered by ID.
+type RoundRobin struct {
+	mu   sync.Mutex
+	last int64
+}
+
+func (r *RoundRobin) SelectMentor(candidates []Mentor, lab *Submission) (*Mentor, error) {
+	if len(candidates) == 0 {
+		return nil, ErrNoMentors
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ordered := make([]*Mentor, len(candidates))
+	for i := range candidates {
+		ordered[i] = &candidates[i]
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].ID < ordered[j].ID })
+	next := ordered[0]
+	for _, c := range ordered {
+		if c.ID > r.last {
+			next = c
+			break
+		}
+	}
+	r.last = next.ID
+	return next, nil
+}
+
+// Weighted picks the mentor with the lowest load relative to their capacity,
+// so a mentor with capacity 2 gets twice as many labs as one with capacity 1.
+// Mentors without a capacity set count as capacity 1.
+type Weighted struct{}
+
+func (Weighted) SelectMentor(candidates []Mentor, lab *Submission) (*Mentor, error) {
+	if len(candidates) == 0 {
+		return nil, ErrNoMentors
+	}
+	best := &candidates[0]
+	for i := range candidates[1:] {
+		c := &candidates[i+1]
+		// c.Load/c.capacity() < best.Load/best.capacity(), without the division
+		lhs, rhs := c.Load*best.capacity(), best.Load*c.capacity()
+		if lhs < rhs || (lhs == rhs && c.ID < best.ID) {
+			best = c
+		}
+	}
+	return best, nil
+}
+
+// Sticky keeps sending a student's labs to the mentor who has reviewed the
+// most of them so far, and defers to Fallback for newcomers.
+type Sticky struct {
+	Fallback AssignmentStrategy
+}
+
+func (s Sticky) SelectMentor(candidates []Mentor, lab *Submission) (*Mentor, error) {
+	var best *Mentor
+	bestCount := 0
+	for i := range candidates {
+		count := 0
+		for _, l := range candidates[i].Submissions {
+			if l.StudentID == lab.StudentID && l.State != StateWithdrawn {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = &candidates[i], count
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+	return s.Fallback.SelectMentor(candidates, lab)
+}
+
+// primaryMentor returns the student's paired mentor if they are still among
+// the candidates and have room for another lab, nil otherwise.
+func primaryMentor(candidates []Mentor, stud *Student) *Mentor {
+	if stud.MentorID == nil {
+		return nil
+	}
+	for i := range candidates {
+		if candidates[i].ID == *stud.MentorID {
+			if candidates[i].full() {
+				return nil
+			}
+			return &candidates[i]
+		}
+	}
+	return nil
+}
+
+// Away reports whether the mentor is on leave at the moment
+func (m *Mentor) Away(now time.Time) bool {
+	if m.AwayFrom.IsZero() || now.Before(m.AwayFrom) {
+		return false
+	}
+	return m.AwayUntil.IsZero() || now.Before(m.AwayUntil)
+}
+
+// available leaves out the mentors who are away
+func available(mentors []Mentor, now time.Time) []Mentor {
+	var res []Mentor
+	for _, m := range mentors {
+		if !m.Away(now) {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// assignable leaves the mentors who can take another lab right now
+func assignable(mentors []Mentor, now time.Time) []Mentor {
+	var res []Mentor
+	for _, m := range available(mentors, now) {
+		if !m.full() {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// full reports whether the mentor already has as many open labs as their
+// capacity allows. Mentors without a capacity are never full.
+func (m *Mentor) full() bool {
+	return m.Capacity > 0 && int64(len(m.Submissions.Open())) >= m.Capacity
+}
+
+func (m *Mentor) capacity() int64 {
+	if m.Capacity < 1 {
+		return 1
+	}
+	return m.Capacity
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStrategies(t *testing.T) {
+	mentors := func() []Mentor {
+		return []Mentor{
+			{ID: 1, Tag: "a", Load: 4, Capacity: 4},
+			{ID: 2, Tag: "b", Load: 2, Capacity: 1},
+			{ID: 3, Tag: "c", Load: 2, Capacity: 2, Submissions: Submissions{
+				{StudentID: 7, State: StateApproved},
+				{StudentID: 7, State: StateSubmitted},
+			}},
+		}
+	}
+	tests := []struct {
+		name     string
+		strategy AssignmentStrategy
+		student  int64
+		want     int64
+	}{
+		{"least loaded, oldest on ties", LeastLoaded{}, 1, 2},
+		{"weighted by capacity", Weighted{}, 1, 1},
+		{"sticky keeps the student's mentor", Sticky{Fallback: LeastLoaded{}}, 7, 3},
+		{"sticky falls back for newcomers", Sticky{Fallback: Weighted{}}, 8, 1},
+		{"round robin starts with the first", &RoundRobin{}, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.strategy.SelectMentor(mentors(), &Submission{StudentID: tt.student})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != tt.want {
+				t.Errorf("got mentor %d, want %d", got.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundRobinTakesTurns(t *testing.T) {
+	rr := &RoundRobin{}
+	var got []int64
+	for i := 0; i < 5; i++ {
+		// the candidates come in any order
+		m, err := rr.SelectMentor([]Mentor{{ID: 3}, {ID: 1}, {ID: 2}}, &Submission{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m.ID)
+	}
+	want := []int64{1, 2, 3, 1, 2}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestStrategiesWithoutCandidates(t *testing.T) {
+	for _, name := range []string{"least_loaded", "round_robin", "weighted", "sticky"} {
+		strategy, err := NewAssignmentStrategy(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := strategy.SelectMentor(nil, &Submission{}); !errors.Is(err, ErrNoMentors) {
+			t.Errorf("%s: got %v, want ErrNoMentors", name, err)
+		}
+	}
+	if _, err := NewAssignmentStrategy("random"); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+}
+
+func TestAssignable(t *testing.T) {
+	now := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	mentors := []Mentor{
+		{ID: 1},
+		{ID: 2, AwayFrom: now.Add(-time.Hour)},
+		{ID: 3, AwayFrom: now.Add(-time.Hour), AwayUntil: now.Add(-time.Minute)},
+		{ID: 4, Capacity: 1, Submissions: Submissions{{State: StateInReview}}},
+		{ID: 5, Capacity: 2, Submissions: Submissions{{State: StateInReview}, {State: StateApproved}}},
+	}
+	var got []int64
+	for _, m := range assignable(mentors, now) {
+		got = append(got, m.ID)
+	}
+	want := []int64{1, 3, 5}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
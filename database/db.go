@@ -3,37 +3,40 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/uptrace/bun"
-	"github.com/uptrace/bun/dialect/pgdialect"
-	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/dialect"
 )
 
-var DB _db
+var (
+	ErrLabExists = errors.New("lab already added")
+	ErrLabClosed = errors.New("lab is not open")
+	ErrLabQueued = errors.New("lab is waiting in the queue")
+)
 
+// _db keeps the data in an SQL database through bun
 type _db struct {
-	sqldb *sql.DB
-	db    *bun.DB
-}
-
-func (d *_db) Init(conn string) {
-	d.sqldb = sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(conn)))
-	d.db = bun.NewDB(d.sqldb, pgdialect.New())
-	d.initTables()
+	sqldb      *sql.DB
+	db         *bun.DB
+	strategy   AssignmentStrategy
+	loadWindow time.Duration
 }
 
-func (d *_db) initTables() {
-	queryCtx := context.Background()
-	go d.db.NewCreateTable().Model((*Mentor)(nil)).IfNotExists().Exec(queryCtx)
-	go d.db.NewCreateTable().Model((*Lab)(nil)).IfNotExists().Exec(queryCtx)
-	go d.db.NewCreateTable().Model((*DoneLab)(nil)).IfNotExists().Exec(queryCtx)
-	go d.db.NewCreateTable().Model((*Student)(nil)).IfNotExists().Exec(queryCtx)
-	go d.db.NewCreateTable().Model((*Admin)(nil)).IfNotExists().Exec(queryCtx)
+// forUpdate locks the selected rows till the end of the transaction. SQLite
+// has no row locks, it serializes whole transactions instead.
+func (d *_db) forUpdate(q *bun.SelectQuery) *bun.SelectQuery {
+	if d.db.Dialect().Name() == dialect.SQLite {
+		return q
+	}
+	return q.For("UPDATE")
 }
 
 func (d *_db) GetMentorById(ctx context.Context, key int64) (*Mentor, error) {
 	ment := new(Mentor)
-	err := d.db.NewSelect().Model(ment).Where("ID = ?", key).Relation("Labs").Relation("DoneLabs").Scan(ctx)
+	err := d.db.NewSelect().Model(ment).Where("ID = ?", key).Relation("Submissions").Relation("Skills").Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +45,7 @@ func (d *_db) GetMentorById(ctx context.Context, key int64) (*Mentor, error) {
 
 func (d *_db) GetMentorByTag(ctx context.Context, key string) (*Mentor, error) {
 	ment := new(Mentor)
-	err := d.db.NewSelect().Model(ment).Where("TAG = ?", key).Relation("Labs").Relation("DoneLabs").Scan(ctx)
+	err := d.db.NewSelect().Model(ment).Where("TAG = ?", key).Relation("Submissions").Relation("Skills").Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +53,6 @@ func (d *_db) GetMentorByTag(ctx context.Context, key string) (*Mentor, error) {
 }
 
 func (d *_db) AddMentor(ctx context.Context, ment *Mentor) error {
-	d.db.NewRaw("SELECT AVG(LOAD) FROM MENTORS").Scan(ctx, &ment.Load)
 	_, err := d.db.NewInsert().Model(ment).On("CONFLICT DO NOTHING").Exec(ctx)
 	if err != nil {
 		return err
@@ -73,15 +75,100 @@ func (d *_db) CheckMentor(ctx context.Context, ment *Mentor) (bool, error) {
 	return d.db.NewSelect().Model(ment).WhereOr("MMST_ID = ?", ment.MmstID).WhereOr("TAG = ?", ment.Tag).Exists(ctx)
 }
 
+func (d *_db) GetMentors(ctx context.Context) ([]Mentor, error) {
+	var res []Mentor
+	err := d.db.NewSelect().Model(&res).Relation("Submissions").Relation("Skills").Scan(ctx)
+	return res, err
+}
+
+// GetForgeAccount finds the user's account on the forge, sql.ErrNoRows if
+// they haven't linked one
+func (d *_db) GetForgeAccount(ctx context.Context, mmstID, forge string) (*ForgeAccount, error) {
+	acc := new(ForgeAccount)
+	err := d.db.NewSelect().Model(acc).Where("MMST_ID = ?", mmstID).Where("FORGE = ?", forge).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// SetForgeAccount links the account, replacing the one the user had on the
+// same forge
+func (d *_db) SetForgeAccount(ctx context.Context, acc *ForgeAccount) error {
+	_, err := d.db.NewInsert().Model(acc).
+		On("CONFLICT (mmst_id, forge) DO UPDATE").
+		Set("login = EXCLUDED.login").
+		Set("code = EXCLUDED.code").
+		Set("verified = EXCLUDED.verified").
+		Set("any_author = EXCLUDED.any_author").
+		Returning("id").
+		Exec(ctx)
+	return err
+}
+
+func (d *_db) RemoveForgeAccount(ctx context.Context, acc *ForgeAccount) error {
+	_, err := d.db.NewDelete().Model((*ForgeAccount)(nil)).Where("MMST_ID = ?", acc.MmstID).Where("FORGE = ?", acc.Forge).Exec(ctx)
+	return err
+}
+
+// SetMentorCapacity limits how many open labs the mentor may have, zero
+// means no limit
+func (d *_db) SetMentorCapacity(ctx context.Context, ment *Mentor, capacity int64) error {
+	ment.Capacity = capacity
+	_, err := d.db.NewUpdate().Model(ment).Column("capacity").Where("ID = ?", ment.ID).Exec(ctx)
+	return err
+}
+
+// SetMentorAway sends the mentor on leave from till until, zero times clear
+// the leave
+func (d *_db) SetMentorAway(ctx context.Context, ment *Mentor, from, until time.Time, handoff bool) error {
+	ment.AwayFrom, ment.AwayUntil, ment.AwayHandoff = from, until, handoff
+	_, err := d.db.NewUpdate().Model(ment).Column("away_from", "away_until", "away_handoff").Where("ID = ?", ment.ID).Exec(ctx)
+	return err
+}
+
+// sameSkill matches the mentor's skills for the same lab number or pattern
+func sameSkill(skill *Skill) func(bun.QueryBuilder) bun.QueryBuilder {
+	return func(q bun.QueryBuilder) bun.QueryBuilder {
+		q = q.Where("MENTOR_ID = ?", skill.MentorID)
+		if skill.Number != nil {
+			return q.Where("NUMBER = ?", *skill.Number)
+		}
+		return q.Where("PATTERN = ?", skill.Pattern)
+	}
+}
+
+// AddSkill gives the mentor the skill unless they already have it
+func (d *_db) AddSkill(ctx context.Context, skill *Skill) error {
+	return d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		exists, err := tx.NewSelect().Model((*Skill)(nil)).ApplyQueryBuilder(sameSkill(skill)).Exists(ctx)
+		if err != nil || exists {
+			return err
+		}
+		_, err = tx.NewInsert().Model(skill).Exec(ctx)
+		return err
+	})
+}
+
+func (d *_db) RemoveSkill(ctx context.Context, skill *Skill) error {
+	_, err := d.db.NewDelete().Model((*Skill)(nil)).ApplyQueryBuilder(sameSkill(skill)).Exec(ctx)
+	return err
+}
+
+func (d *_db) ClearSkills(ctx context.Context, ment *Mentor) error {
+	_, err := d.db.NewDelete().Model((*Skill)(nil)).Where("MENTOR_ID = ?", ment.ID).Exec(ctx)
+	return err
+}
+
 func (d *_db) GetStudents(ctx context.Context) ([]Student, error) {
 	var res []Student
-	err := d.db.NewSelect().Model(&res).Relation("Labs").Relation("DoneLabs").Scan(ctx)
+	err := d.db.NewSelect().Model(&res).Relation("Submissions").Scan(ctx)
 	return res, err
 }
 
 func (d *_db) GetStudentById(ctx context.Context, key int64) (*Student, error) {
 	stud := new(Student)
-	err := d.db.NewSelect().Model(stud).Where("ID = ?", key).Relation("Labs").Relation("DoneLabs").Scan(ctx)
+	err := d.db.NewSelect().Model(stud).Where("ID = ?", key).Relation("Submissions").Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +177,7 @@ func (d *_db) GetStudentById(ctx context.Context, key int64) (*Student, error) {
 
 func (d *_db) GetStudentByTag(ctx context.Context, key string) (*Student, error) {
 	stud := new(Student)
-	err := d.db.NewSelect().Model(stud).Where("TAG = ?", key).Relation("Labs").Relation("DoneLabs").Scan(ctx)
+	err := d.db.NewSelect().Model(stud).Where("TAG = ?", key).Relation("Submissions").Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -111,93 +198,577 @@ func (d *_db) UpdateStudent(ctx context.Context, stud *Student) error {
 	return err
 }
 
-func (d *_db) AddLab(ctx context.Context, lab *Lab) (Mentor, error) {
+func (d *_db) SetStudentMentor(ctx context.Context, stud *Student, ment *Mentor) error {
+	if ment == nil {
+		stud.MentorID = nil
+	} else {
+		stud.MentorID = &ment.ID
+	}
+	_, err := d.db.NewUpdate().Model(stud).Where("ID = ?", stud.ID).Column("mentor_id").Exec(ctx)
+	return err
+}
+
+// AddLab assigns the lab to a mentor and returns them, one with a skill for
+// it if anyone has one. When every such mentor is away or full the lab is
+// put into the queue instead, its state tells which one happened.
+func (d *_db) AddLab(ctx context.Context, lab *Submission) (Mentor, error) {
 	var selectedMentor Mentor
-	err := d.db.NewSelect().Model(&selectedMentor).Order("load asc").Limit(1).Scan(ctx)
+	err := d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// Locking every mentor serializes concurrent assignments, so two labs
+		// can not both see the same mentor as the least loaded one
+		var mentors []Mentor
+		err := d.forUpdate(tx.NewSelect().Model(&mentors).Relation("Submissions").Relation("Skills")).Scan(ctx)
+		if err != nil {
+			return err
+		}
+		if len(mentors) == 0 {
+			return ErrNoMentors
+		}
+		now := time.Now()
+		candidates := assignable(qualified(mentors, lab), now)
+		for i := range candidates {
+			candidates[i].Load = candidates[i].derivedLoad(d.loadWindow, now)
+		}
+		stud := new(Student)
+		err = d.forUpdate(tx.NewSelect().Model(stud).Where("ID = ?", lab.StudentID)).Scan(ctx)
+		if err != nil {
+			return err
+		}
+		exists, err := tx.NewSelect().Model((*Submission)(nil)).Where("URL = ?", lab.Url).Where("STUDENT_ID = ?", lab.StudentID).Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrLabExists
+		}
+		lab.SubmittedAt = now
+		lab.QueuedAt = now
+		lab.UpdatedAt = now
+		switch {
+		case lab.State == StateAwaitingCI:
+			lab.MentorID = 0
+		case len(candidates) == 0:
+			lab.MentorID = 0
+			lab.State = StateQueued
+		default:
+			lab.State = StateSubmitted
+		}
+		var selected *Mentor
+		if lab.State == StateSubmitted {
+			selected = primaryMentor(candidates, stud)
+			if selected == nil {
+				selected, err = d.strategy.SelectMentor(candidates, lab)
+				if err != nil {
+					return err
+				}
+			}
+			lab.MentorID = selected.ID
+		}
+		_, err = tx.NewInsert().Model(lab).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewInsert().Model(&Transition{
+			SubmissionID: lab.ID,
+			ToState:      lab.State,
+			Actor:        stud.MmstID,
+			CreatedAt:    now,
+		}).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if selected == nil {
+			return nil
+		}
+		selected.Submissions = append(selected.Submissions, lab)
+		selected.Load = selected.derivedLoad(d.loadWindow, now)
+		_, err = tx.NewUpdate().Model(selected).Column("load").Where("ID = ?", selected.ID).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if stud.MentorID == nil {
+			stud.MentorID = &selected.ID
+			_, err = tx.NewUpdate().Model(stud).Where("ID = ?", stud.ID).Column("mentor_id").Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		selectedMentor = *selected
+		return nil
+	})
+	return selectedMentor, err
+}
+
+func (d *_db) GetSubmission(ctx context.Context, key int64) (*Submission, error) {
+	sub := new(Submission)
+	err := d.db.NewSelect().Model(sub).Where("ID = ?", key).Scan(ctx)
 	if err != nil {
-		return selectedMentor, err
+		return nil, err
 	}
-	selectedMentor.Load += 2
-	d.db.NewUpdate().Model(&selectedMentor).Where("ID = ?", selectedMentor.ID).Column("load").Exec(ctx)
-	lab.MentorID = selectedMentor.ID
-	_, err = d.db.NewInsert().Model(lab).On("CONFLICT DO NOTHING").Exec(ctx)
+	return sub, nil
+}
+
+// GetSubmissions finds the submissions of the same URL by the same student
+func (d *_db) GetSubmissions(ctx context.Context, sub *Submission) ([]Submission, error) {
+	var subs []Submission
+	err := d.db.NewSelect().Model(&subs).Where("URL = ?", sub.Url).Where("STUDENT_ID = ?", sub.StudentID).Scan(ctx)
+	return subs, err
+}
+
+// GetSubmissionsByUrl finds the submissions of the URL by anyone
+func (d *_db) GetSubmissionsByUrl(ctx context.Context, url string) ([]Submission, error) {
+	var subs []Submission
+	err := d.db.NewSelect().Model(&subs).Where("URL = ?", url).Scan(ctx)
+	return subs, err
+}
+
+// GetSubmissionsByNumber finds the submissions of the same lab of the same
+// course by the same student, the latest sent first
+func (d *_db) GetSubmissionsByNumber(ctx context.Context, sub *Submission) ([]Submission, error) {
+	var subs []Submission
+	err := d.db.NewSelect().Model(&subs).Where("STUDENT_ID = ?", sub.StudentID).Where("COURSE = ?", sub.Course).Where("NUMBER = ?", sub.Number).Order("id desc").Scan(ctx)
+	return subs, err
+}
+
+// SetLabPost remembers the post the mentor reviews the lab from
+func (d *_db) SetLabPost(ctx context.Context, lab *Submission, postID string) error {
+	lab.PostID = postID
+	_, err := d.db.NewUpdate().Model(lab).Column("post_id").WherePK().Exec(ctx)
+	return err
+}
+
+// SetPullInfo saves what the forge said about the lab's pull request
+func (d *_db) SetPullInfo(ctx context.Context, lab *Submission) error {
+	_, err := d.db.NewUpdate().Model(lab).Column("pull_title", "pull_author", "pull_state", "head_sha", "changed_files", "ci_state").WherePK().Exec(ctx)
+	return err
+}
+
+// SetLateness saves how late the lab is
+func (d *_db) SetLateness(ctx context.Context, lab *Submission) error {
+	_, err := d.db.NewUpdate().Model(lab).Column("lateness").WherePK().Exec(ctx)
+	return err
+}
+
+// GetDeadlines lists every deadline by course and lab number
+func (d *_db) GetDeadlines(ctx context.Context) ([]Deadline, error) {
+	var res []Deadline
+	err := d.db.NewSelect().Model(&res).Order("course asc", "number asc").Scan(ctx)
+	return res, err
+}
+
+// GetDeadline finds when the lab of the course is due, sql.ErrNoRows if
+// it's never
+func (d *_db) GetDeadline(ctx context.Context, course string, number int64) (*Deadline, error) {
+	dl := new(Deadline)
+	err := d.db.NewSelect().Model(dl).Where("COURSE = ?", course).Where("NUMBER = ?", number).Scan(ctx)
 	if err != nil {
-		return selectedMentor, err
+		return nil, err
 	}
-	return selectedMentor, err
+	return dl, nil
 }
 
-func (d *_db) UpdateLab(ctx context.Context, lab *Lab) error {
-	_, err := d.db.NewUpdate().Model(lab).WherePK().Exec(ctx)
+// SetDeadline sets the deadline, replacing the one the lab had
+func (d *_db) SetDeadline(ctx context.Context, dl *Deadline) error {
+	_, err := d.db.NewInsert().Model(dl).
+		On("CONFLICT (course, number) DO UPDATE").
+		Set("soft_at = EXCLUDED.soft_at").
+		Set("hard_at = EXCLUDED.hard_at").
+		Returning("id").
+		Exec(ctx)
 	return err
 }
 
-func (d *_db) GetLabPK(ctx context.Context, lab *Lab) error {
-	return d.db.NewSelect().Model(lab).Where("ID = ?", lab.ID).Scan(ctx)
+func (d *_db) RemoveDeadline(ctx context.Context, dl *Deadline) error {
+	_, err := d.db.NewDelete().Model((*Deadline)(nil)).Where("COURSE = ?", dl.Course).Where("NUMBER = ?", dl.Number).Exec(ctx)
+	return err
 }
 
-func (d *_db) GetDoneLabPK(ctx context.Context, lab *DoneLab) error {
-	return d.db.NewSelect().Model(lab).Where("ID = ?", lab.ID).Scan(ctx)
+// ReassignLab hands an open lab over to another mentor picked by the
+// assignment strategy, its current mentor is never picked. The lab keeps its
+// state, the hand-off is recorded in its history. lab is reloaded with the
+// new mentor.
+func (d *_db) ReassignLab(ctx context.Context, lab *Submission, actor string) (Mentor, error) {
+	var selectedMentor Mentor
+	err := d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var mentors []Mentor
+		err := d.forUpdate(tx.NewSelect().Model(&mentors).Relation("Submissions").Relation("Skills")).Scan(ctx)
+		if err != nil {
+			return err
+		}
+		err = d.forUpdate(tx.NewSelect().Model(lab).WherePK()).Scan(ctx)
+		if err != nil {
+			return err
+		}
+		if !lab.Open() {
+			return ErrLabClosed
+		}
+		if lab.MentorID == 0 {
+			return ErrLabQueued
+		}
+		now := time.Now()
+		var others []Mentor
+		previous := "a removed mentor"
+		for _, ment := range mentors {
+			if ment.ID == lab.MentorID {
+				previous = "@" + ment.Tag
+				continue
+			}
+			others = append(others, ment)
+		}
+		candidates := assignable(qualified(others, lab), now)
+		for i := range candidates {
+			candidates[i].Load = candidates[i].derivedLoad(d.loadWindow, now)
+		}
+		selected, err := d.strategy.SelectMentor(candidates, lab)
+		if err != nil {
+			return err
+		}
+		from := lab.MentorID
+		lab.MentorID = selected.ID
+		lab.UpdatedAt = now
+		_, err = tx.NewUpdate().Model(lab).Column("mentor_id", "updated_at").WherePK().Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewInsert().Model(&Transition{
+			SubmissionID: lab.ID,
+			FromState:    lab.State,
+			ToState:      lab.State,
+			Actor:        actor,
+			Comment:      fmt.Sprintf("reassigned from %s to @%s", previous, selected.Tag),
+			CreatedAt:    now,
+		}).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if err := d.storeLoad(ctx, tx, from); err != nil {
+			return err
+		}
+		if err := d.storeLoad(ctx, tx, selected.ID); err != nil {
+			return err
+		}
+		selectedMentor = *selected
+		return nil
+	})
+	return selectedMentor, err
 }
 
-func (d *_db) GetLabs(ctx context.Context, lab *Lab) ([]Lab, error) {
-	var labs []Lab
-	err := d.db.NewSelect().Model(&labs).Where("URL = ?", lab.Url).Where("STUDENT_ID = ?", lab.StudentID).Scan(ctx)
-	return labs, err
+// AssignQueued hands queued labs, oldest first, to the mentors who have
+// room for them now and returns the labs that found a mentor. A lab whose
+// skilled mentors are all busy doesn't hold back the ones behind it.
+func (d *_db) AssignQueued(ctx context.Context) ([]Submission, error) {
+	var assigned []Submission
+	err := d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var mentors []Mentor
+		err := d.forUpdate(tx.NewSelect().Model(&mentors).Relation("Submissions").Relation("Skills")).Scan(ctx)
+		if err != nil {
+			return err
+		}
+		var queue []Submission
+		err = d.forUpdate(tx.NewSelect().Model(&queue).Where("STATE = ?", StateQueued).Order("queued_at asc", "id asc")).Scan(ctx)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for i := range queue {
+			lab := &queue[i]
+			candidates := assignable(qualified(mentors, lab), now)
+			if len(candidates) == 0 {
+				// the mentors for this one are busy, others may not be
+				continue
+			}
+			for j := range candidates {
+				candidates[j].Load = candidates[j].derivedLoad(d.loadWindow, now)
+			}
+			stud := new(Student)
+			err = d.forUpdate(tx.NewSelect().Model(stud).Where("ID = ?", lab.StudentID)).Scan(ctx)
+			if err != nil {
+				return err
+			}
+			selected := primaryMentor(candidates, stud)
+			if selected == nil {
+				selected, err = d.strategy.SelectMentor(candidates, lab)
+				if err != nil {
+					return err
+				}
+			}
+			lab.MentorID = selected.ID
+			_, err = tx.NewUpdate().Model(lab).Column("mentor_id").WherePK().Exec(ctx)
+			if err != nil {
+				return err
+			}
+			err = d.transition(ctx, tx, lab, StateSubmitted, "", "")
+			if err != nil {
+				return err
+			}
+			if stud.MentorID == nil {
+				stud.MentorID = &selected.ID
+				_, err = tx.NewUpdate().Model(stud).Where("ID = ?", stud.ID).Column("mentor_id").Exec(ctx)
+				if err != nil {
+					return err
+				}
+			}
+			for j := range mentors {
+				if mentors[j].ID == selected.ID {
+					mentors[j].Submissions = append(mentors[j].Submissions, lab)
+				}
+			}
+			assigned = append(assigned, *lab)
+		}
+		return nil
+	})
+	return assigned, err
 }
 
-func (d *_db) GetStudentLabs(ctx context.Context, stud *Student) ([]Lab, error) {
-	var labs []Lab
-	err := d.db.NewSelect().Model(&labs).Where("STUDENT_ID = ?", stud.ID).Scan(ctx)
-	return labs, err
+// QueuePosition tells how many labs are ahead of the queued lab, counting
+// itself
+func (d *_db) QueuePosition(ctx context.Context, lab *Submission) (int, error) {
+	return d.db.NewSelect().Model((*Submission)(nil)).
+		Where("STATE = ?", StateQueued).
+		Where("(QUEUED_AT < ? OR (QUEUED_AT = ? AND ID <= ?))", lab.QueuedAt, lab.QueuedAt, lab.ID).
+		Count(ctx)
 }
 
-func (d *_db) FinishLab(ctx context.Context, lab *Lab) error {
-	var selectedMentor Mentor
-	err := d.db.NewSelect().Model(&selectedMentor).Where("ID = ?", lab.MentorID).Column("load").Scan(ctx)
+// Transition moves the submission to another state on behalf of actor and
+// records it in the history. sub is reloaded with the new state.
+func (d *_db) Transition(ctx context.Context, sub *Submission, to, actor, comment string) error {
+	return d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return d.transition(ctx, tx, sub, to, actor, comment)
+	})
+}
+
+func (d *_db) transition(ctx context.Context, tx bun.Tx, sub *Submission, to, actor, comment string) error {
+	err := d.forUpdate(tx.NewSelect().Model(sub).WherePK()).Scan(ctx)
 	if err != nil {
 		return err
 	}
-	selectedMentor.Load -= 1
-	d.db.NewUpdate().Model(&selectedMentor).Where("ID = ?", lab.MentorID).Column("load").Exec(ctx)
-	doneLab := DoneLab{
-		ID:        lab.ID,
-		Url:       lab.Url,
-		StudentID: lab.StudentID,
-		MentorID:  lab.MentorID,
-		Number:    lab.Number,
+	if err := checkTransition(sub.State, to); err != nil {
+		return err
+	}
+	now := time.Now()
+	from := sub.State
+	sub.State = to
+	sub.UpdatedAt = now
+	if to == StateChangesRequested {
+		sub.Revisions++
+	}
+	if to == StateApproved {
+		sub.FinishedAt = now
+	} else {
+		sub.FinishedAt = time.Time{}
 	}
-	_, err = d.db.NewInsert().Model(&doneLab).On("CONFLICT DO NOTHING").Exec(ctx)
+	// a withdrawn lab goes to the back of the queue, one sent back for
+	// changes or moved between mentors keeps its place
+	if from == StateWithdrawn {
+		sub.QueuedAt = now
+	}
+	_, err = tx.NewUpdate().Model(sub).Column("state", "updated_at", "queued_at", "finished_at", "revisions").WherePK().Exec(ctx)
 	if err != nil {
 		return err
 	}
-	_, err = d.db.NewDelete().Model(lab).WherePK().Exec(ctx)
-	return err
+	_, err = tx.NewInsert().Model(&Transition{
+		SubmissionID: sub.ID,
+		FromState:    from,
+		ToState:      to,
+		Actor:        actor,
+		Comment:      comment,
+		CreatedAt:    now,
+	}).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return d.storeLoad(ctx, tx, sub.MentorID)
 }
 
-func (d *_db) UnfinishLab(ctx context.Context, lab *DoneLab) error {
-	var selectedMentor Mentor
-	err := d.db.NewSelect().Model(&selectedMentor).Where("ID = ?", lab.MentorID).Column("load").Scan(ctx)
-	if err != nil {
+func (d *_db) FinishLab(ctx context.Context, lab *Submission, actor string) error {
+	return d.Transition(ctx, lab, StateApproved, actor, "")
+}
+
+// GradeLab approves the lab with the score
+func (d *_db) GradeLab(ctx context.Context, lab *Submission, actor string, score int64) error {
+	return d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := d.transition(ctx, tx, lab, StateApproved, actor, fmt.Sprintf("scored %d", score)); err != nil {
+			return err
+		}
+		lab.Score = &score
+		_, err := tx.NewUpdate().Model(lab).Column("score").WherePK().Exec(ctx)
+		return err
+	})
+}
+
+// UnfinishLab takes the approval back, the score with it
+func (d *_db) UnfinishLab(ctx context.Context, lab *Submission, actor string) error {
+	return d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := d.transition(ctx, tx, lab, StateInReview, actor, ""); err != nil {
+			return err
+		}
+		lab.Score = nil
+		_, err := tx.NewUpdate().Model(lab).Column("score").WherePK().Exec(ctx)
 		return err
+	})
+}
+
+func (d *_db) StartReview(ctx context.Context, lab *Submission, actor string) error {
+	return d.Transition(ctx, lab, StateInReview, actor, "")
+}
+
+// RequestChanges sends the lab back to the student, it stays with its mentor
+// until the student resubmits
+func (d *_db) RequestChanges(ctx context.Context, lab *Submission, actor, comment string) error {
+	return d.Transition(ctx, lab, StateChangesRequested, actor, comment)
+}
+
+// ResubmitLab puts a lab with requested changes back into review
+func (d *_db) ResubmitLab(ctx context.Context, lab *Submission, actor string) error {
+	return d.Transition(ctx, lab, StateSubmitted, actor, "")
+}
+
+// GetParkedLabs finds the labs waiting for CI of the commit
+func (d *_db) GetParkedLabs(ctx context.Context, headSHA string) ([]Submission, error) {
+	var subs []Submission
+	err := d.db.NewSelect().Model(&subs).Where("STATE = ?", StateAwaitingCI).Where("HEAD_SHA = ?", headSHA).Scan(ctx)
+	return subs, err
+}
+
+// ParkLab holds a resubmitted lab back until its CI passes
+func (d *_db) ParkLab(ctx context.Context, lab *Submission, actor string) error {
+	return d.Transition(ctx, lab, StateAwaitingCI, actor, "")
+}
+
+// PassCI lets a lab waiting for CI go on, back to its mentor if it has one
+// and into the queue otherwise
+func (d *_db) PassCI(ctx context.Context, lab *Submission) error {
+	to := StateQueued
+	if lab.MentorID != 0 {
+		to = StateSubmitted
 	}
-	selectedMentor.Load += 1
-	d.db.NewUpdate().Model(&selectedMentor).Where("ID = ?", lab.MentorID).Column("load").Exec(ctx)
-	undoneLab := Lab{
-		ID:        lab.ID,
-		Url:       lab.Url,
-		StudentID: lab.StudentID,
-		MentorID:  lab.MentorID,
-		Number:    lab.Number,
+	return d.Transition(ctx, lab, to, "", "CI passed")
+}
+
+// WithdrawLab calls the lab off, it can be resubmitted later
+func (d *_db) WithdrawLab(ctx context.Context, lab *Submission, actor, comment string) error {
+	return d.Transition(ctx, lab, StateWithdrawn, actor, comment)
+}
+
+// PushLab records new commits in the lab's pull request. The lab keeps its
+// state, the push is kept in its history.
+func (d *_db) PushLab(ctx context.Context, lab *Submission, headSHA string) error {
+	return d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := d.forUpdate(tx.NewSelect().Model(lab).WherePK()).Scan(ctx)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		lab.HeadSHA = headSHA
+		lab.UpdatedAt = now
+		_, err = tx.NewUpdate().Model(lab).Column("head_sha", "updated_at").WherePK().Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewInsert().Model(&Transition{
+			SubmissionID: lab.ID,
+			FromState:    lab.State,
+			ToState:      lab.State,
+			Comment:      fmt.Sprintf("pushed %.7s", headSHA),
+			CreatedAt:    now,
+		}).Exec(ctx)
+		return err
+	})
+}
+
+// MoveLab gives the lab another URL along with what the forge said about
+// it. The lab keeps its state and mentor, the move is kept in its history.
+func (d *_db) MoveLab(ctx context.Context, lab *Submission, url, actor string) error {
+	return d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		exists, err := tx.NewSelect().Model((*Submission)(nil)).Where("URL = ?", url).Where("STUDENT_ID = ?", lab.StudentID).Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrLabExists
+		}
+		var state string
+		err = d.forUpdate(tx.NewSelect().Model((*Submission)(nil)).Column("state").Where("ID = ?", lab.ID)).Scan(ctx, &state)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		old := lab.Url
+		lab.Url = url
+		lab.State = state
+		lab.UpdatedAt = now
+		_, err = tx.NewUpdate().Model(lab).Column("url", "updated_at", "pull_title", "pull_author", "pull_state", "head_sha", "changed_files", "ci_state").WherePK().Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewInsert().Model(&Transition{
+			SubmissionID: lab.ID,
+			FromState:    state,
+			ToState:      state,
+			Actor:        actor,
+			Comment:      fmt.Sprintf("moved from %s", old),
+			CreatedAt:    now,
+		}).Exec(ctx)
+		return err
+	})
+}
+
+// GetHistory lists the transitions of the submission, oldest first
+func (d *_db) GetHistory(ctx context.Context, sub *Submission) ([]Transition, error) {
+	var history []Transition
+	err := d.db.NewSelect().Model(&history).Where("SUBMISSION_ID = ?", sub.ID).Order("created_at asc", "id asc").Scan(ctx)
+	return history, err
+}
+
+// storeLoad recomputes the mentor's load from their labs and saves it
+func (d *_db) storeLoad(ctx context.Context, db bun.IDB, mentorID int64) error {
+	ment := new(Mentor)
+	err := d.forUpdate(db.NewSelect().Model(ment).Where("ID = ?", mentorID).Relation("Submissions")).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		// the mentor was removed, nobody to keep the load for
+		return nil
 	}
-	_, err = d.db.NewInsert().Model(&undoneLab).On("CONFLICT DO NOTHING").Exec(ctx)
 	if err != nil {
 		return err
 	}
-	_, err = d.db.NewDelete().Model(lab).WherePK().Exec(ctx)
+	ment.Load = ment.derivedLoad(d.loadWindow, time.Now())
+	_, err = db.NewUpdate().Model(ment).Column("load").Where("ID = ?", ment.ID).Exec(ctx)
 	return err
 }
 
+// CheckLoad reports mentors whose stored load differs from the one derived
+// from their labs.
+func (d *_db) CheckLoad(ctx context.Context) ([]LoadDrift, error) {
+	var mentors []Mentor
+	err := d.db.NewSelect().Model(&mentors).Relation("Submissions").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return loadDrifts(mentors, d.loadWindow, time.Now()), nil
+}
+
+// RecalcLoad overwrites every drifted mentor load with the derived one and
+// reports what was changed.
+func (d *_db) RecalcLoad(ctx context.Context) ([]LoadDrift, error) {
+	var drifts []LoadDrift
+	err := d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var mentors []Mentor
+		err := d.forUpdate(tx.NewSelect().Model(&mentors).Relation("Submissions")).Scan(ctx)
+		if err != nil {
+			return err
+		}
+		drifts = loadDrifts(mentors, d.loadWindow, time.Now())
+		for _, drift := range drifts {
+			_, err = tx.NewUpdate().Model((*Mentor)(nil)).Set("load = ?", drift.Actual).Where("ID = ?", drift.Mentor.ID).Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return drifts, err
+}
+
 func (d *_db) CheckAdmin(ctx context.Context, adm *Admin) (bool, error) {
 	cnt, err := d.db.NewSelect().Model((*Admin)(nil)).WhereOr("MMST_ID = ?", adm.MmstID).WhereOr("TAG = ?", adm.Tag).Count(ctx)
 	return cnt > 0, err
@@ -0,0 +1,358 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testConns are the databases to test against: a fresh SQLite one, and the
+// PostgreSQL one at $MOSTFUL_TEST_POSTGRES if it's set. Tests roll the
+// PostgreSQL one back to nothing once done, so it has to be a disposable one.
+func testConns(t *testing.T) map[string]string {
+	t.Helper()
+	conns := map[string]string{
+		"sqlite": "sqlite://" + filepath.Join(t.TempDir(), "test.db"),
+	}
+	if pg := os.Getenv("MOSTFUL_TEST_POSTGRES"); pg != "" {
+		conns["postgres"] = pg
+	}
+	return conns
+}
+
+// testStores opens the test databases with every migration applied
+func testStores(t *testing.T, strategy AssignmentStrategy) map[string]Store {
+	t.Helper()
+	conns := testConns(t)
+	stores := map[string]Store{}
+	for name, conn := range conns {
+		store, err := Init(context.Background(), conn, strategy, 0)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		t.Cleanup(func() { rollbackAll(t, store) })
+		stores[name] = store
+	}
+	return stores
+}
+
+// rollbackAll reverts every applied migration
+func rollbackAll(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+	for {
+		status, err := store.MigrationStatus(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(status.Applied()) == 0 {
+			return
+		}
+		if err := store.Rollback(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func addStudents(t *testing.T, store Store, n int) []*Student {
+	t.Helper()
+	students := make([]*Student, n)
+	for i := range students {
+		students[i] = &Student{MmstID: fmt.Sprintf("s%d", i), Tag: fmt.Sprintf("s%d", i)}
+		if err := store.AddStudent(context.Background(), students[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return students
+}
+
+func TestConcurrentAssignment(t *testing.T) {
+	const labs = 300
+	for name, store := range testStores(t, LeastLoaded{}) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			for i := 0; i < 3; i++ {
+				if err := store.AddMentor(ctx, &Mentor{MmstID: fmt.Sprintf("m%d", i), Tag: fmt.Sprintf("m%d", i)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			students := addStudents(t, store, labs)
+			submissions := make([]*Submission, labs)
+			var wg sync.WaitGroup
+			errs := make(chan error, labs)
+			for i := range students {
+				submissions[i] = &Submission{
+					Url:       fmt.Sprintf("https://github.com/o/01-lab-01-s%d/pull/1", i),
+					StudentID: students[i].ID,
+					Number:    1,
+				}
+				wg.Add(1)
+				go func(lab *Submission) {
+					defer wg.Done()
+					_, err := store.AddLab(ctx, lab)
+					errs <- err
+				}(submissions[i])
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			mentors, err := store.GetMentors(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var total int64
+			for _, m := range mentors {
+				total += m.Load
+				if got := len(m.Submissions.Open()); got != labs/3 {
+					t.Errorf("@%s got %d labs, want %d", m.Tag, got, labs/3)
+				}
+			}
+			if total != openLabLoad*labs {
+				t.Errorf("total load %d, want %d", total, openLabLoad*labs)
+			}
+
+			for _, lab := range submissions {
+				wg.Add(1)
+				go func(lab *Submission) {
+					defer wg.Done()
+					if err := store.FinishLab(ctx, lab, "m"); err != nil {
+						t.Error(err)
+					}
+				}(lab)
+			}
+			wg.Wait()
+			drifts, err := store.CheckLoad(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(drifts) > 0 {
+				t.Errorf("load drifted: %+v", drifts)
+			}
+			mentors, _ = store.GetMentors(ctx)
+			for _, m := range mentors {
+				if m.Load != 0 {
+					t.Errorf("@%s has load %d with every lab finished", m.Tag, m.Load)
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrentAssignmentQueues(t *testing.T) {
+	const labs, capacity = 100, 10
+	for name, store := range testStores(t, &RoundRobin{}) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			for i := 0; i < 2; i++ {
+				m := &Mentor{MmstID: fmt.Sprintf("m%d", i), Tag: fmt.Sprintf("m%d", i)}
+				if err := store.AddMentor(ctx, m); err != nil {
+					t.Fatal(err)
+				}
+				if err := store.SetMentorCapacity(ctx, m, capacity); err != nil {
+					t.Fatal(err)
+				}
+			}
+			students := addStudents(t, store, labs)
+			var wg sync.WaitGroup
+			for i := range students {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					_, err := store.AddLab(ctx, &Submission{
+						Url:       fmt.Sprintf("https://github.com/o/01-lab-01-s%d/pull/1", i),
+						StudentID: students[i].ID,
+						Number:    1,
+					})
+					if err != nil {
+						t.Error(err)
+					}
+				}(i)
+			}
+			wg.Wait()
+			mentors, err := store.GetMentors(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, m := range mentors {
+				if got := len(m.Submissions.Open()); got != capacity {
+					t.Errorf("@%s got %d labs, want %d", m.Tag, got, capacity)
+				}
+			}
+			queued := 0
+			for _, student := range students {
+				stud, err := store.GetStudentById(ctx, student.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stud.Submissions[0].State == StateQueued {
+					queued++
+				}
+			}
+			if want := labs - 2*capacity; queued != want {
+				t.Errorf("%d labs queued, want %d", queued, want)
+			}
+		})
+	}
+}
+
+func TestOpenConn(t *testing.T) {
+	store, err := Init(context.Background(), "sqlite://:memory:", LeastLoaded{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddMentor(context.Background(), &Mentor{MmstID: "m", Tag: "m"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, conn := range []string{"memory://", "mysql://localhost/db", "file.db"} {
+		if _, err := Open(conn, LeastLoaded{}, 0); err == nil {
+			t.Errorf("%s opened, want an error", conn)
+		}
+	}
+}
+
+func TestQueuedAt(t *testing.T) {
+	for name, store := range testStores(t, LeastLoaded{}) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := store.AddMentor(ctx, &Mentor{MmstID: "m", Tag: "m"}); err != nil {
+				t.Fatal(err)
+			}
+			stud := addStudents(t, store, 1)[0]
+			lab := &Submission{Url: "https://github.com/o/01-lab-01-s0/pull/1", StudentID: stud.ID, Number: 1}
+			if _, err := store.AddLab(ctx, lab); err != nil {
+				t.Fatal(err)
+			}
+			// as the database keeps them
+			lab, err := store.GetSubmission(ctx, lab.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sent := lab.SubmittedAt
+			if !lab.QueuedAt.Equal(sent) {
+				t.Errorf("new lab queued at %s, sent at %s", lab.QueuedAt, sent)
+			}
+			steps := []struct {
+				name    string
+				move    func() error
+				requeue bool
+			}{
+				{"changes requested", func() error { return store.RequestChanges(ctx, lab, "m", "fix") }, false},
+				{"resubmitted", func() error { return store.ResubmitLab(ctx, lab, "s") }, false},
+				{"withdrawn", func() error { return store.WithdrawLab(ctx, lab, "s", "") }, false},
+				{"back from withdrawn", func() error { return store.ResubmitLab(ctx, lab, "s") }, true},
+			}
+			queued := lab.QueuedAt
+			for _, step := range steps {
+				time.Sleep(10 * time.Millisecond)
+				if err := step.move(); err != nil {
+					t.Fatalf("%s: %s", step.name, err)
+				}
+				if !lab.SubmittedAt.Equal(sent) {
+					t.Errorf("%s: sent at %s, want %s", step.name, lab.SubmittedAt, sent)
+				}
+				if moved := !lab.QueuedAt.Equal(queued); moved != step.requeue {
+					t.Errorf("%s: queued at %s, was %s", step.name, lab.QueuedAt, queued)
+				}
+				queued = lab.QueuedAt
+			}
+		})
+	}
+}
+
+func TestUnfinishClearsScore(t *testing.T) {
+	for name, store := range testStores(t, LeastLoaded{}) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := store.AddMentor(ctx, &Mentor{MmstID: "m", Tag: "m"}); err != nil {
+				t.Fatal(err)
+			}
+			stud := addStudents(t, store, 1)[0]
+			lab := &Submission{Url: "https://github.com/o/01-lab-01-s0/pull/1", StudentID: stud.ID, Number: 1}
+			if _, err := store.AddLab(ctx, lab); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.GradeLab(ctx, lab, "m", 7); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.UnfinishLab(ctx, lab, "m"); err != nil {
+				t.Fatal(err)
+			}
+			saved, err := store.GetSubmission(ctx, lab.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.State != StateInReview || saved.Score != nil || lab.Score != nil {
+				t.Errorf("unfinished lab is %s with score %v, want it in review without one", saved.State, saved.Score)
+			}
+		})
+	}
+}
+
+func TestSkilledMentorsFirst(t *testing.T) {
+	for name, store := range testStores(t, LeastLoaded{}) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			anyone := &Mentor{MmstID: "a", Tag: "a"}
+			skilled := &Mentor{MmstID: "s", Tag: "s"}
+			for _, m := range []*Mentor{anyone, skilled} {
+				if err := store.AddMentor(ctx, m); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := store.SetMentorCapacity(ctx, skilled, 1); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.AddSkill(ctx, &Skill{MentorID: skilled.ID, Pattern: "*-lab-03-*"}); err != nil {
+				t.Fatal(err)
+			}
+			studs := addStudents(t, store, 3)
+			labs := []*Submission{
+				{Url: "https://github.com/o/01-lab-03-s0/pull/1", StudentID: studs[0].ID, Number: 3},
+				{Url: "https://github.com/o/01-lab-03-s1/pull/1", StudentID: studs[1].ID, Number: 3},
+				{Url: "https://github.com/o/01-lab-01-s2/pull/1", StudentID: studs[2].ID, Number: 1},
+			}
+			for _, lab := range labs {
+				if _, err := store.AddLab(ctx, lab); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := []struct {
+				state  string
+				mentor int64
+			}{
+				{StateSubmitted, skilled.ID},
+				{StateQueued, 0},
+				{StateSubmitted, anyone.ID},
+			}
+			for i, lab := range labs {
+				if lab.State != want[i].state || lab.MentorID != want[i].mentor {
+					t.Errorf("%s is %s with mentor %d, want %s with %d", lab.Url, lab.State, lab.MentorID, want[i].state, want[i].mentor)
+				}
+			}
+
+			if assigned, err := store.AssignQueued(ctx); err != nil || len(assigned) != 0 {
+				t.Fatalf("assigned %v with the skilled mentor full, %v", assigned, err)
+			}
+			if err := store.FinishLab(ctx, labs[0], "s"); err != nil {
+				t.Fatal(err)
+			}
+			assigned, err := store.AssignQueued(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(assigned) != 1 || assigned[0].ID != labs[1].ID || assigned[0].MentorID != skilled.ID {
+				t.Errorf("assigned %+v, want the queued lab given to the skilled mentor", assigned)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package database
+
+import "time"
+
+// How late a submission is
+const (
+	OnTime = ""
+	// Late is past the soft deadline
+	Late = "late"
+	// Overdue is past the hard deadline
+	Overdue = "overdue"
+)
+
+// Lateness tells how late a submission made at the time is
+func (dl *Deadline) Lateness(at time.Time) string {
+	switch {
+	case !dl.HardAt.IsZero() && at.After(dl.HardAt):
+		return Overdue
+	case at.After(dl.SoftAt):
+		return Late
+	}
+	return OnTime
+}
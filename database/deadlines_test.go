@@ -0,0 +1,31 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLateness(t *testing.T) {
+	soft := time.Date(2026, 10, 1, 23, 59, 0, 0, time.UTC)
+	hard := soft.AddDate(0, 0, 7)
+	tests := []struct {
+		name string
+		dl   Deadline
+		at   time.Time
+		want string
+	}{
+		{"before", Deadline{SoftAt: soft, HardAt: hard}, soft.Add(-time.Hour), OnTime},
+		{"right at the deadline", Deadline{SoftAt: soft, HardAt: hard}, soft, OnTime},
+		{"a second late", Deadline{SoftAt: soft, HardAt: hard}, soft.Add(time.Second), Late},
+		{"right at the hard deadline", Deadline{SoftAt: soft, HardAt: hard}, hard, Late},
+		{"past the hard deadline", Deadline{SoftAt: soft, HardAt: hard}, hard.Add(time.Second), Overdue},
+		{"no hard deadline", Deadline{SoftAt: soft}, soft.AddDate(1, 0, 0), Late},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dl.Lateness(tt.at); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A submission starts as submitted, may be taken into review, sent back for
+// changes and resubmitted any number of times, and ends up approved or
+// withdrawn. Approval can be taken back, withdrawn labs can be resubmitted.
+// When every mentor is full the submission waits in the queue first, without
+// a mentor, until one has room for it. A submission whose CI is still running
+// waits for it to pass, and goes on to its mentor, or to the queue if it has
+// none yet.
+const (
+	StateAwaitingCI       = "awaiting_ci"
+	StateQueued           = "queued"
+	StateSubmitted        = "submitted"
+	StateInReview         = "in_review"
+	StateChangesRequested = "changes_requested"
+	StateApproved         = "approved"
+	StateWithdrawn        = "withdrawn"
+)
+
+var transitions = map[string][]string{
+	StateAwaitingCI:       {StateQueued, StateSubmitted, StateWithdrawn},
+	StateQueued:           {StateSubmitted, StateWithdrawn},
+	StateSubmitted:        {StateInReview, StateChangesRequested, StateApproved, StateWithdrawn},
+	StateInReview:         {StateChangesRequested, StateApproved, StateWithdrawn},
+	StateChangesRequested: {StateSubmitted, StateAwaitingCI, StateApproved, StateWithdrawn},
+	StateApproved:         {StateInReview},
+	StateWithdrawn:        {StateSubmitted, StateAwaitingCI, StateQueued},
+}
+
+var ErrBadTransition = errors.New("submission can not go there from its state")
+
+func checkTransition(from, to string) error {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s -> %s", ErrBadTransition, from, to)
+}
+
+// Open reports whether the submission still waits for its mentor
+func (s *Submission) Open() bool {
+	switch s.State {
+	case StateAwaitingCI, StateQueued, StateSubmitted, StateInReview, StateChangesRequested:
+		return true
+	}
+	return false
+}
+
+type Submissions []*Submission
+
+func (subs Submissions) Open() Submissions {
+	var open Submissions
+	for _, s := range subs {
+		if s.Open() {
+			open = append(open, s)
+		}
+	}
+	return open
+}
+
+func (subs Submissions) Approved() Submissions {
+	var approved Submissions
+	for _, s := range subs {
+		if s.State == StateApproved {
+			approved = append(approved, s)
+		}
+	}
+	return approved
+}
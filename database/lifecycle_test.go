@@ -0,0 +1,66 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransitions(t *testing.T) {
+	tests := []struct {
+		from, to string
+		ok       bool
+	}{
+		{StateSubmitted, StateInReview, true},
+		{StateSubmitted, StateApproved, true},
+		{StateInReview, StateChangesRequested, true},
+		{StateChangesRequested, StateSubmitted, true},
+		{StateChangesRequested, StateAwaitingCI, true},
+		{StateApproved, StateInReview, true},
+		{StateWithdrawn, StateSubmitted, true},
+		{StateWithdrawn, StateQueued, true},
+		{StateAwaitingCI, StateQueued, true},
+		{StateQueued, StateSubmitted, true},
+		{StateQueued, StateApproved, false},
+		{StateApproved, StateWithdrawn, false},
+		{StateApproved, StateSubmitted, false},
+		{StateWithdrawn, StateApproved, false},
+		{StateInReview, StateSubmitted, false},
+		{StateSubmitted, StateSubmitted, false},
+		{"", StateSubmitted, false},
+	}
+	for _, tt := range tests {
+		err := checkTransition(tt.from, tt.to)
+		if tt.ok && err != nil {
+			t.Errorf("%s -> %s: %s", tt.from, tt.to, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrBadTransition) {
+			t.Errorf("%s -> %s: got %v, want ErrBadTransition", tt.from, tt.to, err)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	open := map[string]bool{
+		StateAwaitingCI:       true,
+		StateQueued:           true,
+		StateSubmitted:        true,
+		StateInReview:         true,
+		StateChangesRequested: true,
+		StateApproved:         false,
+		StateWithdrawn:        false,
+	}
+	var subs Submissions
+	for state, want := range open {
+		sub := &Submission{State: state}
+		if sub.Open() != want {
+			t.Errorf("%s: Open() = %v, want %v", state, !want, want)
+		}
+		subs = append(subs, sub)
+	}
+	if got := len(subs.Open()); got != 5 {
+		t.Errorf("%d open submissions, want 5", got)
+	}
+	if got := len(subs.Approved()); got != 1 {
+		t.Errorf("%d approved submissions, want 1", got)
+	}
+}
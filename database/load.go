@@ -0,0 +1,46 @@
+package database
+
+import "time"
+
+// An open lab weighs more than a finished one: the mentor still has to
+// review it, while a finished lab may come back after a disapproval.
+const (
+	openLabLoad = 2
+	doneLabLoad = 1
+)
+
+type LoadDrift struct {
+	Mentor Mentor
+	Stored int64
+	Actual int64
+}
+
+// derivedLoad computes the mentor's load from their open labs and the labs
+// they finished within window. Submissions must be loaded.
+func (m *Mentor) derivedLoad(window time.Duration, now time.Time) int64 {
+	load := int64(openLabLoad * len(m.Submissions.Open()))
+	if window <= 0 {
+		return load
+	}
+	for _, lab := range m.Submissions.Approved() {
+		if !lab.FinishedAt.IsZero() && now.Sub(lab.FinishedAt) < window {
+			load += doneLabLoad
+		}
+	}
+	return load
+}
+
+func loadDrifts(mentors []Mentor, window time.Duration, now time.Time) []LoadDrift {
+	var drifts []LoadDrift
+	for _, m := range mentors {
+		actual := m.derivedLoad(window, now)
+		if actual != m.Load {
+			drifts = append(drifts, LoadDrift{
+				Mentor: m,
+				Stored: m.Load,
+				Actual: actual,
+			})
+		}
+	}
+	return drifts
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"log"
+
+	"github.com/uptrace/bun/migrate"
+	"github.com/zinstack625/mostful_manager/database/migrations"
+)
+
+func (d *_db) migrator(ctx context.Context) (*migrate.Migrator, error) {
+	migrator := migrate.NewMigrator(d.db, migrations.Migrations)
+	return migrator, migrator.Init(ctx)
+}
+
+// Migrate applies every pending migration
+func (d *_db) Migrate(ctx context.Context) error {
+	migrator, err := d.migrator(ctx)
+	if err != nil {
+		return err
+	}
+	group, err := migrator.Migrate(ctx)
+	if err != nil {
+		return err
+	}
+	if group.IsZero() {
+		log.Println("Database is up to date")
+	} else {
+		log.Printf("Database migrated to %s", group)
+	}
+	return nil
+}
+
+// Rollback reverts the last group of applied migrations
+func (d *_db) Rollback(ctx context.Context) error {
+	migrator, err := d.migrator(ctx)
+	if err != nil {
+		return err
+	}
+	group, err := migrator.Rollback(ctx)
+	if err != nil {
+		return err
+	}
+	if group.IsZero() {
+		log.Println("Nothing to roll back")
+	} else {
+		log.Printf("Rolled back %s", group)
+	}
+	return nil
+}
+
+func (d *_db) MigrationStatus(ctx context.Context) (migrate.MigrationSlice, error) {
+	migrator, err := d.migrator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return migrator.MigrationsWithStatus(ctx)
+}
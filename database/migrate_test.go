@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun/migrate"
+	"github.com/zinstack625/mostful_manager/database/migrations"
+)
+
+// migrateEach applies the first n migrations, one group each
+func migrateEach(ctx context.Context, t *testing.T, store Store, n int) {
+	t.Helper()
+	sorted := migrations.Migrations.Sorted()
+	for i := range sorted[:n] {
+		upTo := migrate.NewMigrations()
+		for _, m := range sorted[:i+1] {
+			upTo.Add(m)
+		}
+		migrator := migrate.NewMigrator(store.(*_db).db, upTo)
+		if err := migrator.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := migrator.Migrate(ctx); err != nil {
+			t.Fatalf("up %s: %s", sorted[i].Name, err)
+		}
+	}
+}
+
+// TestMigrations applies the migrations one group each, rolls every one of
+// them back with some labs in the database and applies them all again
+func TestMigrations(t *testing.T) {
+	for name, conn := range testConns(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			store, err := Open(conn, LeastLoaded{}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sorted := migrations.Migrations.Sorted()
+			migrateEach(ctx, t, store, len(sorted))
+
+			mentor := &Mentor{MmstID: "m", Tag: "m"}
+			if err := store.AddMentor(ctx, mentor); err != nil {
+				t.Fatal(err)
+			}
+			student := &Student{MmstID: "s", Tag: "s"}
+			if err := store.AddStudent(ctx, student); err != nil {
+				t.Fatal(err)
+			}
+			lab := &Submission{Url: "https://github.com/o/01-lab-01-s/pull/1", StudentID: student.ID, Number: 1}
+			if _, err := store.AddLab(ctx, lab); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.FinishLab(ctx, lab, "m"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.AddLab(ctx, &Submission{Url: "https://github.com/o/01-lab-02-s/pull/1", StudentID: student.ID, Number: 2}); err != nil {
+				t.Fatal(err)
+			}
+
+			for left := len(sorted); left > 0; left-- {
+				if err := store.Rollback(ctx); err != nil {
+					t.Fatalf("down %s: %s", sorted[left-1].Name, err)
+				}
+				status, err := store.MigrationStatus(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if applied := len(status.Applied()); applied != left-1 {
+					t.Fatalf("%d migrations applied after rolling %s back, want %d", applied, sorted[left-1].Name, left-1)
+				}
+			}
+
+			if err := store.Migrate(ctx); err != nil {
+				t.Fatal(err)
+			}
+			defer rollbackAll(t, store)
+			if err := store.AddMentor(ctx, &Mentor{MmstID: "m", Tag: "m"}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCanonicalURLsMigration(t *testing.T) {
+	urls := map[string]string{
+		"https://www.GitHub.com/Owner/01-Lab-03-Name/pull/7/files": "https://github.com/owner/01-lab-03-name/pull/7",
+		"http://gitlab.com/Group/Sub/repo/-/merge_requests/2/":     "https://gitlab.com/group/sub/repo/-/merge_requests/2",
+		"https://github.com/owner/repo/pull/1":                     "https://github.com/owner/repo/pull/1",
+		"https://git.example.edu/Owner/repo/pulls/1":               "https://git.example.edu/Owner/repo/pulls/1",
+		"not a url": "not a url",
+	}
+	for name, conn := range testConns(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			store, err := Open(conn, LeastLoaded{}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rollbackAll(t, store)
+			sorted := migrations.Migrations.Sorted()
+			canonical := 0
+			for i, m := range sorted {
+				if m.Name == "20261018000016" {
+					canonical = i
+				}
+			}
+			if canonical == 0 {
+				t.Fatal("no canonical URLs migration")
+			}
+			migrateEach(ctx, t, store, canonical)
+			db := store.(*_db).db
+			for raw := range urls {
+				_, err := db.ExecContext(ctx, `INSERT INTO "submissions" ("url", "student_id", "mentor_id", "number") VALUES (?, 1, 1, 1)`, raw)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			stored := func() map[string]bool {
+				var got []string
+				if err := db.NewSelect().Table("submissions").Column("url").Scan(ctx, &got); err != nil {
+					t.Fatal(err)
+				}
+				set := map[string]bool{}
+				for _, url := range got {
+					set[url] = true
+				}
+				return set
+			}
+
+			migrateEach(ctx, t, store, canonical+1)
+			got := stored()
+			for raw, want := range urls {
+				if !got[want] {
+					t.Errorf("%s: no %s after up in %v", raw, want, got)
+				}
+			}
+			if err := store.Rollback(ctx); err != nil {
+				t.Fatal(err)
+			}
+			got = stored()
+			for raw := range urls {
+				if !got[raw] {
+					t.Errorf("%s not put back by down, got %v", raw, got)
+				}
+			}
+		})
+	}
+}
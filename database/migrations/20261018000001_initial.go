@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// The tables as they used to be created straight from the models, so
+// deployments predating migrations pass through untouched.
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG: {
+				`CREATE TABLE IF NOT EXISTS "mentors" ("id" BIGSERIAL NOT NULL, "mmst_id" VARCHAR, "tag" VARCHAR NOT NULL, "load" BIGINT, PRIMARY KEY ("id", "tag"), UNIQUE ("mmst_id"))`,
+				`CREATE TABLE IF NOT EXISTS "labs" ("id" BIGSERIAL NOT NULL, "url" VARCHAR, "student_id" BIGINT, "mentor_id" BIGINT, "number" BIGINT, PRIMARY KEY ("id"))`,
+				`CREATE TABLE IF NOT EXISTS "done_labs" ("id" BIGSERIAL NOT NULL, "url" VARCHAR, "student_id" BIGINT, "mentor_id" BIGINT, "number" BIGINT, PRIMARY KEY ("id"))`,
+				`CREATE TABLE IF NOT EXISTS "students" ("id" BIGSERIAL NOT NULL, "mmst_id" VARCHAR, "tag" VARCHAR NOT NULL, "real_name" VARCHAR, PRIMARY KEY ("id", "tag"), UNIQUE ("mmst_id"))`,
+				`CREATE TABLE IF NOT EXISTS "admins" ("id" BIGSERIAL NOT NULL, "mmst_id" VARCHAR, "tag" VARCHAR NOT NULL, PRIMARY KEY ("id", "tag"), UNIQUE ("mmst_id"))`,
+			},
+			// SQLite only autoincrements a lone INTEGER PRIMARY KEY
+			dialect.SQLite: {
+				`CREATE TABLE IF NOT EXISTS "mentors" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "mmst_id" VARCHAR UNIQUE, "tag" VARCHAR NOT NULL, "load" BIGINT)`,
+				`CREATE TABLE IF NOT EXISTS "labs" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "url" VARCHAR, "student_id" BIGINT, "mentor_id" BIGINT, "number" BIGINT)`,
+				`CREATE TABLE IF NOT EXISTS "done_labs" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "url" VARCHAR, "student_id" BIGINT, "mentor_id" BIGINT, "number" BIGINT)`,
+				`CREATE TABLE IF NOT EXISTS "students" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "mmst_id" VARCHAR UNIQUE, "tag" VARCHAR NOT NULL, "real_name" VARCHAR)`,
+				`CREATE TABLE IF NOT EXISTS "admins" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "mmst_id" VARCHAR UNIQUE, "tag" VARCHAR NOT NULL)`,
+			},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		drop := []string{
+			`DROP TABLE IF EXISTS "admins"`,
+			`DROP TABLE IF EXISTS "students"`,
+			`DROP TABLE IF EXISTS "done_labs"`,
+			`DROP TABLE IF EXISTS "labs"`,
+			`DROP TABLE IF EXISTS "mentors"`,
+		}
+		return queries{dialect.PG: drop, dialect.SQLite: drop}.exec(ctx, db)
+	})
+}
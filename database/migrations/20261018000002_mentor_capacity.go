@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG: {
+				`ALTER TABLE "mentors" ADD COLUMN IF NOT EXISTS "capacity" BIGINT NOT NULL DEFAULT 0`,
+				// fresh tables created from the model got a nullable column
+				`UPDATE "mentors" SET "capacity" = 0 WHERE "capacity" IS NULL`,
+				`ALTER TABLE "mentors" ALTER COLUMN "capacity" SET DEFAULT 0, ALTER COLUMN "capacity" SET NOT NULL`,
+			},
+			dialect.SQLite: {
+				`ALTER TABLE "mentors" ADD COLUMN "capacity" BIGINT NOT NULL DEFAULT 0`,
+			},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "mentors" DROP COLUMN IF EXISTS "capacity"`},
+			dialect.SQLite: {`ALTER TABLE "mentors" DROP COLUMN "capacity"`},
+		}.exec(ctx, db)
+	})
+}
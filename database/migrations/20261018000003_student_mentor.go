@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "students" ADD COLUMN IF NOT EXISTS "mentor_id" BIGINT`},
+			dialect.SQLite: {`ALTER TABLE "students" ADD COLUMN "mentor_id" BIGINT`},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "students" DROP COLUMN IF EXISTS "mentor_id"`},
+			dialect.SQLite: {`ALTER TABLE "students" DROP COLUMN "mentor_id"`},
+		}.exec(ctx, db)
+	})
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "done_labs" ADD COLUMN IF NOT EXISTS "finished_at" TIMESTAMPTZ`},
+			dialect.SQLite: {`ALTER TABLE "done_labs" ADD COLUMN "finished_at" TIMESTAMP`},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "done_labs" DROP COLUMN IF EXISTS "finished_at"`},
+			dialect.SQLite: {`ALTER TABLE "done_labs" DROP COLUMN "finished_at"`},
+		}.exec(ctx, db)
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG: {
+				`ALTER TABLE "labs" ADD COLUMN "status" VARCHAR NOT NULL DEFAULT 'submitted'`,
+				`CREATE TABLE "rejections" ("id" BIGSERIAL PRIMARY KEY, "lab_id" BIGINT NOT NULL, "student_id" BIGINT NOT NULL, "mentor_id" BIGINT NOT NULL, "comment" TEXT NOT NULL, "created_at" TIMESTAMPTZ NOT NULL DEFAULT now())`,
+			},
+			dialect.SQLite: {
+				`ALTER TABLE "labs" ADD COLUMN "status" VARCHAR NOT NULL DEFAULT 'submitted'`,
+				`CREATE TABLE "rejections" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "lab_id" BIGINT NOT NULL, "student_id" BIGINT NOT NULL, "mentor_id" BIGINT NOT NULL, "comment" TEXT NOT NULL, "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)`,
+			},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		down := []string{
+			`DROP TABLE "rejections"`,
+			`ALTER TABLE "labs" DROP COLUMN "status"`,
+		}
+		return queries{dialect.PG: down, dialect.SQLite: down}.exec(ctx, db)
+	})
+}
@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// Folds labs, done_labs and rejections into submissions with a state and the
+// transitions that lead there. Submissions keep the IDs of the labs, which
+// are already referenced from mentor DMs.
+func init() {
+	fold := []string{
+		`INSERT INTO "submissions" ("id", "url", "student_id", "mentor_id", "number", "state")
+			SELECT "id", COALESCE("url", ''), COALESCE("student_id", 0), COALESCE("mentor_id", 0), COALESCE("number", 0), "status" FROM "labs"`,
+		`INSERT INTO "submissions" ("id", "url", "student_id", "mentor_id", "number", "state", "finished_at")
+			SELECT "id", COALESCE("url", ''), COALESCE("student_id", 0), COALESCE("mentor_id", 0), COALESCE("number", 0), 'approved', "finished_at" FROM "done_labs"
+			WHERE true ON CONFLICT DO NOTHING`,
+		`INSERT INTO "transitions" ("submission_id", "from_state", "to_state", "actor", "comment", "created_at")
+			SELECT "r"."lab_id", 'submitted', 'changes_requested', COALESCE("m"."mmst_id", ''), "r"."comment", "r"."created_at"
+			FROM "rejections" AS "r" LEFT JOIN "mentors" AS "m" ON "m"."id" = "r"."mentor_id"`,
+		`INSERT INTO "transitions" ("submission_id", "from_state", "to_state", "comment")
+			SELECT "id", '', "state", 'migrated' FROM "submissions"`,
+		`DROP TABLE "rejections"`,
+		`DROP TABLE "done_labs"`,
+		`DROP TABLE "labs"`,
+	}
+	unfold := []string{
+		`INSERT INTO "labs" ("id", "url", "student_id", "mentor_id", "number", "status")
+			SELECT "id", "url", "student_id", "mentor_id", "number", CASE WHEN "state" = 'changes_requested' THEN "state" ELSE 'submitted' END
+			FROM "submissions" WHERE "state" IN ('submitted', 'in_review', 'changes_requested')`,
+		`INSERT INTO "done_labs" ("id", "url", "student_id", "mentor_id", "number", "finished_at")
+			SELECT "id", "url", "student_id", "mentor_id", "number", "finished_at" FROM "submissions" WHERE "state" = 'approved'`,
+		`INSERT INTO "rejections" ("lab_id", "student_id", "mentor_id", "comment", "created_at")
+			SELECT "t"."submission_id", "s"."student_id", "s"."mentor_id", "t"."comment", "t"."created_at"
+			FROM "transitions" AS "t" JOIN "submissions" AS "s" ON "s"."id" = "t"."submission_id"
+			WHERE "t"."to_state" = 'changes_requested'`,
+		`DROP TABLE "transitions"`,
+		`DROP TABLE "submissions"`,
+	}
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		pg := []string{
+			`CREATE TABLE "submissions" ("id" BIGSERIAL PRIMARY KEY, "url" VARCHAR NOT NULL, "student_id" BIGINT NOT NULL, "mentor_id" BIGINT NOT NULL, "number" BIGINT NOT NULL, "state" VARCHAR NOT NULL DEFAULT 'submitted', "submitted_at" TIMESTAMPTZ NOT NULL DEFAULT now(), "updated_at" TIMESTAMPTZ NOT NULL DEFAULT now(), "finished_at" TIMESTAMPTZ)`,
+			`CREATE TABLE "transitions" ("id" BIGSERIAL PRIMARY KEY, "submission_id" BIGINT NOT NULL, "from_state" VARCHAR NOT NULL DEFAULT '', "to_state" VARCHAR NOT NULL, "actor" VARCHAR NOT NULL DEFAULT '', "comment" TEXT NOT NULL DEFAULT '', "created_at" TIMESTAMPTZ NOT NULL DEFAULT now())`,
+			`CREATE INDEX "transitions_submission_id_idx" ON "transitions" ("submission_id")`,
+		}
+		pg = append(pg, fold...)
+		pg = append(pg, `SELECT setval(pg_get_serial_sequence('submissions', 'id'), GREATEST((SELECT MAX("id") FROM "submissions"), 1))`)
+		sqlite := []string{
+			`CREATE TABLE "submissions" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "url" VARCHAR NOT NULL, "student_id" BIGINT NOT NULL, "mentor_id" BIGINT NOT NULL, "number" BIGINT NOT NULL, "state" VARCHAR NOT NULL DEFAULT 'submitted', "submitted_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, "finished_at" TIMESTAMP)`,
+			`CREATE TABLE "transitions" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "submission_id" BIGINT NOT NULL, "from_state" VARCHAR NOT NULL DEFAULT '', "to_state" VARCHAR NOT NULL, "actor" VARCHAR NOT NULL DEFAULT '', "comment" TEXT NOT NULL DEFAULT '', "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)`,
+			`CREATE INDEX "transitions_submission_id_idx" ON "transitions" ("submission_id")`,
+		}
+		sqlite = append(sqlite, fold...)
+		return queries{dialect.PG: pg, dialect.SQLite: sqlite}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		pg := []string{
+			`CREATE TABLE "labs" ("id" BIGSERIAL PRIMARY KEY, "url" VARCHAR, "student_id" BIGINT, "mentor_id" BIGINT, "number" BIGINT, "status" VARCHAR NOT NULL DEFAULT 'submitted')`,
+			`CREATE TABLE "done_labs" ("id" BIGSERIAL PRIMARY KEY, "url" VARCHAR, "student_id" BIGINT, "mentor_id" BIGINT, "number" BIGINT, "finished_at" TIMESTAMPTZ)`,
+			`CREATE TABLE "rejections" ("id" BIGSERIAL PRIMARY KEY, "lab_id" BIGINT NOT NULL, "student_id" BIGINT NOT NULL, "mentor_id" BIGINT NOT NULL, "comment" TEXT NOT NULL, "created_at" TIMESTAMPTZ NOT NULL DEFAULT now())`,
+			// labs allocate IDs for done_labs too
+			`SELECT setval(pg_get_serial_sequence('labs', 'id'), GREATEST((SELECT MAX("id") FROM "submissions"), 1))`,
+		}
+		pg = append(pg, unfold...)
+		sqlite := []string{
+			`CREATE TABLE "labs" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "url" VARCHAR, "student_id" BIGINT, "mentor_id" BIGINT, "number" BIGINT, "status" VARCHAR NOT NULL DEFAULT 'submitted')`,
+			`CREATE TABLE "done_labs" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "url" VARCHAR, "student_id" BIGINT, "mentor_id" BIGINT, "number" BIGINT, "finished_at" TIMESTAMP)`,
+			`CREATE TABLE "rejections" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "lab_id" BIGINT NOT NULL, "student_id" BIGINT NOT NULL, "mentor_id" BIGINT NOT NULL, "comment" TEXT NOT NULL, "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)`,
+			`INSERT INTO "sqlite_sequence" ("name", "seq") SELECT 'labs', COALESCE(MAX("id"), 0) FROM "submissions"`,
+		}
+		sqlite = append(sqlite, unfold...)
+		return queries{dialect.PG: pg, dialect.SQLite: sqlite}.exec(ctx, db)
+	})
+}
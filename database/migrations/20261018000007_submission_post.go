@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "submissions" ADD COLUMN IF NOT EXISTS "post_id" VARCHAR`},
+			dialect.SQLite: {`ALTER TABLE "submissions" ADD COLUMN "post_id" VARCHAR`},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "submissions" DROP COLUMN IF EXISTS "post_id"`},
+			dialect.SQLite: {`ALTER TABLE "submissions" DROP COLUMN "post_id"`},
+		}.exec(ctx, db)
+	})
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG: {
+				`ALTER TABLE "mentors" ADD COLUMN IF NOT EXISTS "away_from" TIMESTAMPTZ`,
+				`ALTER TABLE "mentors" ADD COLUMN IF NOT EXISTS "away_until" TIMESTAMPTZ`,
+				`ALTER TABLE "mentors" ADD COLUMN IF NOT EXISTS "away_handoff" BOOLEAN NOT NULL DEFAULT false`,
+			},
+			dialect.SQLite: {
+				`ALTER TABLE "mentors" ADD COLUMN "away_from" TIMESTAMP`,
+				`ALTER TABLE "mentors" ADD COLUMN "away_until" TIMESTAMP`,
+				`ALTER TABLE "mentors" ADD COLUMN "away_handoff" BOOLEAN NOT NULL DEFAULT false`,
+			},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG: {
+				`ALTER TABLE "mentors" DROP COLUMN IF EXISTS "away_handoff"`,
+				`ALTER TABLE "mentors" DROP COLUMN IF EXISTS "away_until"`,
+				`ALTER TABLE "mentors" DROP COLUMN IF EXISTS "away_from"`,
+			},
+			dialect.SQLite: {
+				`ALTER TABLE "mentors" DROP COLUMN "away_handoff"`,
+				`ALTER TABLE "mentors" DROP COLUMN "away_until"`,
+				`ALTER TABLE "mentors" DROP COLUMN "away_from"`,
+			},
+		}.exec(ctx, db)
+	})
+}
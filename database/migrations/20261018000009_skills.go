@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG: {
+				`CREATE TABLE "skills" ("id" BIGSERIAL PRIMARY KEY, "mentor_id" BIGINT NOT NULL, "number" BIGINT, "pattern" VARCHAR)`,
+				`CREATE INDEX "skills_mentor_id_idx" ON "skills" ("mentor_id")`,
+			},
+			dialect.SQLite: {
+				`CREATE TABLE "skills" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "mentor_id" BIGINT NOT NULL, "number" BIGINT, "pattern" VARCHAR)`,
+				`CREATE INDEX "skills_mentor_id_idx" ON "skills" ("mentor_id")`,
+			},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		down := []string{`DROP TABLE "skills"`}
+		return queries{dialect.PG: down, dialect.SQLite: down}.exec(ctx, db)
+	})
+}
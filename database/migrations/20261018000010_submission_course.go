@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "submissions" ADD COLUMN IF NOT EXISTS "course" VARCHAR NOT NULL DEFAULT ''`},
+			dialect.SQLite: {`ALTER TABLE "submissions" ADD COLUMN "course" VARCHAR NOT NULL DEFAULT ''`},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "submissions" DROP COLUMN IF EXISTS "course"`},
+			dialect.SQLite: {`ALTER TABLE "submissions" DROP COLUMN "course"`},
+		}.exec(ctx, db)
+	})
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+var pullInfoColumns = []string{"pull_title", "pull_author", "pull_state", "head_sha"}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		var pg, sqlite []string
+		for _, column := range pullInfoColumns {
+			pg = append(pg, `ALTER TABLE "submissions" ADD COLUMN IF NOT EXISTS "`+column+`" VARCHAR`)
+			sqlite = append(sqlite, `ALTER TABLE "submissions" ADD COLUMN "`+column+`" VARCHAR`)
+		}
+		pg = append(pg, `ALTER TABLE "submissions" ADD COLUMN IF NOT EXISTS "changed_files" BIGINT`)
+		sqlite = append(sqlite, `ALTER TABLE "submissions" ADD COLUMN "changed_files" BIGINT`)
+		return queries{dialect.PG: pg, dialect.SQLite: sqlite}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		var pg, sqlite []string
+		for _, column := range append(pullInfoColumns, "changed_files") {
+			pg = append(pg, `ALTER TABLE "submissions" DROP COLUMN IF EXISTS "`+column+`"`)
+			sqlite = append(sqlite, `ALTER TABLE "submissions" DROP COLUMN "`+column+`"`)
+		}
+		return queries{dialect.PG: pg, dialect.SQLite: sqlite}.exec(ctx, db)
+	})
+}
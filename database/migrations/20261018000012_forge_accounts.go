@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG: {
+				`CREATE TABLE "forge_accounts" ("id" BIGSERIAL PRIMARY KEY, "mmst_id" VARCHAR NOT NULL, "forge" VARCHAR NOT NULL, "login" VARCHAR NOT NULL, "code" VARCHAR, "verified" BOOLEAN NOT NULL DEFAULT FALSE, "any_author" BOOLEAN NOT NULL DEFAULT FALSE, "created_at" TIMESTAMPTZ NOT NULL DEFAULT now())`,
+				`CREATE UNIQUE INDEX "forge_accounts_mmst_id_forge_idx" ON "forge_accounts" ("mmst_id", "forge")`,
+			},
+			dialect.SQLite: {
+				`CREATE TABLE "forge_accounts" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "mmst_id" VARCHAR NOT NULL, "forge" VARCHAR NOT NULL, "login" VARCHAR NOT NULL, "code" VARCHAR, "verified" BOOLEAN NOT NULL DEFAULT FALSE, "any_author" BOOLEAN NOT NULL DEFAULT FALSE, "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)`,
+				`CREATE UNIQUE INDEX "forge_accounts_mmst_id_forge_idx" ON "forge_accounts" ("mmst_id", "forge")`,
+			},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		down := []string{`DROP TABLE "forge_accounts"`}
+		return queries{dialect.PG: down, dialect.SQLite: down}.exec(ctx, db)
+	})
+}
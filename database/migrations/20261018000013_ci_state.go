@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "submissions" ADD COLUMN IF NOT EXISTS "ci_state" VARCHAR`},
+			dialect.SQLite: {`ALTER TABLE "submissions" ADD COLUMN "ci_state" VARCHAR`},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "submissions" DROP COLUMN IF EXISTS "ci_state"`},
+			dialect.SQLite: {`ALTER TABLE "submissions" DROP COLUMN "ci_state"`},
+		}.exec(ctx, db)
+	})
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG: {
+				`CREATE TABLE "deadlines" ("id" BIGSERIAL PRIMARY KEY, "course" VARCHAR NOT NULL DEFAULT '', "number" BIGINT NOT NULL, "soft_at" TIMESTAMPTZ NOT NULL, "hard_at" TIMESTAMPTZ)`,
+				`CREATE UNIQUE INDEX "deadlines_course_number_idx" ON "deadlines" ("course", "number")`,
+				`ALTER TABLE "submissions" ADD COLUMN IF NOT EXISTS "lateness" VARCHAR`,
+			},
+			dialect.SQLite: {
+				`CREATE TABLE "deadlines" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "course" VARCHAR NOT NULL DEFAULT '', "number" BIGINT NOT NULL, "soft_at" TIMESTAMP NOT NULL, "hard_at" TIMESTAMP)`,
+				`CREATE UNIQUE INDEX "deadlines_course_number_idx" ON "deadlines" ("course", "number")`,
+				`ALTER TABLE "submissions" ADD COLUMN "lateness" VARCHAR`,
+			},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`DROP TABLE "deadlines"`, `ALTER TABLE "submissions" DROP COLUMN IF EXISTS "lateness"`},
+			dialect.SQLite: {`DROP TABLE "deadlines"`, `ALTER TABLE "submissions" DROP COLUMN "lateness"`},
+		}.exec(ctx, db)
+	})
+}
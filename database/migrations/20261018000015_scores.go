@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// countRevisions fills revisions in for the labs that were around before
+const countRevisions = `UPDATE "submissions" SET "revisions" = (SELECT COUNT(*) FROM "transitions" WHERE "transitions"."submission_id" = "submissions"."id" AND "transitions"."to_state" = 'changes_requested')`
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG: {
+				`ALTER TABLE "submissions" ADD COLUMN IF NOT EXISTS "score" BIGINT`,
+				`ALTER TABLE "submissions" ADD COLUMN IF NOT EXISTS "revisions" BIGINT NOT NULL DEFAULT 0`,
+				countRevisions,
+			},
+			dialect.SQLite: {
+				`ALTER TABLE "submissions" ADD COLUMN "score" BIGINT`,
+				`ALTER TABLE "submissions" ADD COLUMN "revisions" BIGINT NOT NULL DEFAULT 0`,
+				countRevisions,
+			},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "submissions" DROP COLUMN IF EXISTS "score"`, `ALTER TABLE "submissions" DROP COLUMN IF EXISTS "revisions"`},
+			dialect.SQLite: {`ALTER TABLE "submissions" DROP COLUMN "score"`, `ALTER TABLE "submissions" DROP COLUMN "revisions"`},
+		}.exec(ctx, db)
+	})
+}
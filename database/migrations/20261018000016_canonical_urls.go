@@ -0,0 +1,98 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// Labs sent before URLs were made canonical may be written any way the
+// student liked, the ones on public forges are rewritten so that the same
+// pull request is never taken twice. The URLs as they were are kept in
+// submission_urls for down to put back.
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			_, err := tx.ExecContext(ctx, `CREATE TABLE "submission_urls" ("id" BIGINT PRIMARY KEY, "url" VARCHAR NOT NULL)`)
+			if err != nil {
+				return err
+			}
+			var labs []struct {
+				ID  int64
+				Url string
+			}
+			err = tx.NewSelect().Table("submissions").Column("id", "url").Scan(ctx, &labs)
+			if err != nil {
+				return err
+			}
+			for _, lab := range labs {
+				canonical, ok := canonicalURL(lab.Url)
+				if !ok || canonical == lab.Url {
+					continue
+				}
+				_, err = tx.ExecContext(ctx, `INSERT INTO "submission_urls" ("id", "url") VALUES (?, ?)`, lab.ID, lab.Url)
+				if err != nil {
+					return err
+				}
+				_, err = tx.NewUpdate().Table("submissions").Set("url = ?", canonical).Where("id = ?", lab.ID).Exec(ctx)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			_, err := tx.ExecContext(ctx, `UPDATE "submissions" SET "url" = (SELECT "url" FROM "submission_urls" WHERE "submission_urls"."id" = "submissions"."id") WHERE "id" IN (SELECT "id" FROM "submission_urls")`)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, `DROP TABLE "submission_urls"`)
+			return err
+		})
+	})
+}
+
+// canonicalURL is the canonical form of a pull request URL on a public forge
+// as it was when the migration was written, false for anything else. The
+// forge package may change its mind later, this must not.
+func canonicalURL(raw string) (string, bool) {
+	pullPaths := map[string]string{
+		"github.com":    "pull",
+		"gitlab.com":    "-/merge_requests",
+		"gitea.com":     "pulls",
+		"codeberg.org":  "pulls",
+		"bitbucket.org": "pull-requests",
+	}
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	pullPath, ok := pullPaths[host]
+	if !ok {
+		return "", false
+	}
+	repo, rest, ok := strings.Cut(u.Path, "/"+pullPath+"/")
+	if !ok {
+		return "", false
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	number, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || number < 1 {
+		return "", false
+	}
+	slash := strings.LastIndex(repo, "/")
+	if slash <= 0 {
+		return "", false
+	}
+	owner := strings.Trim(repo[:slash], "/")
+	if owner == "" || (host != "gitlab.com" && strings.Contains(owner, "/")) {
+		return "", false
+	}
+	return strings.ToLower(fmt.Sprintf("https://%s/%s/%s/%s/%d", host, owner, repo[slash+1:], pullPath, number)), true
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// queuedAt starts the labs that were around before where they were sent
+const queuedAt = `UPDATE "submissions" SET "queued_at" = "submitted_at"`
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG: {
+				`ALTER TABLE "submissions" ADD COLUMN IF NOT EXISTS "queued_at" TIMESTAMPTZ`,
+				queuedAt,
+			},
+			dialect.SQLite: {
+				`ALTER TABLE "submissions" ADD COLUMN "queued_at" TIMESTAMP`,
+				queuedAt,
+			},
+		}.exec(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		return queries{
+			dialect.PG:     {`ALTER TABLE "submissions" DROP COLUMN IF EXISTS "queued_at"`},
+			dialect.SQLite: {`ALTER TABLE "submissions" DROP COLUMN "queued_at"`},
+		}.exec(ctx, db)
+	})
+}
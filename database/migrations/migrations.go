@@ -0,0 +1,34 @@
+// Package migrations holds the numbered schema migrations of the bot. Every
+// file registers an up and a down step, named after the file.
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/migrate"
+)
+
+var Migrations = migrate.NewMigrations()
+
+// queries are the statements of a migration step for every supported dialect
+type queries map[dialect.Name][]string
+
+// exec runs the queries for the database's dialect one by one in a single
+// transaction
+func (q queries) exec(ctx context.Context, db *bun.DB) error {
+	stmts, ok := q[db.Dialect().Name()]
+	if !ok {
+		return fmt.Errorf("no migration for %s", db.Dialect().Name())
+	}
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, stmt := range stmts {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
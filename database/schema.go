@@ -1,6 +1,10 @@
 package database
 
-import "github.com/uptrace/bun"
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
 
 type Mentor struct {
 	bun.BaseModel `bun:"table:mentors"`
@@ -8,8 +12,25 @@ type Mentor struct {
 	MmstID        string `bun:",unique"`
 	Tag           string `bun:",pk"`
 	Load          int64
-	Labs          []*Lab     `bun:"rel:has-many,join:id=mentor_id"`
-	DoneLabs      []*DoneLab `bun:"rel:has-many,join:id=mentor_id"`
+	Capacity      int64
+	// The mentor gets no labs from AwayFrom till AwayUntil, an unset
+	// AwayUntil means until they say they're back. AwayHandoff asks for their
+	// open labs to be reassigned once they're gone.
+	AwayFrom    time.Time `bun:",nullzero"`
+	AwayUntil   time.Time `bun:",nullzero"`
+	AwayHandoff bool
+	Submissions Submissions `bun:"rel:has-many,join:id=mentor_id"`
+	Skills      []*Skill    `bun:"rel:has-many,join:id=mentor_id"`
+}
+
+// Skill is a kind of labs the mentor reviews: either the lab with Number, or
+// the labs whose repository name matches Pattern, a glob like "*-lab-03-*"
+type Skill struct {
+	bun.BaseModel `bun:"table:skills"`
+	ID            int64 `bun:",pk,autoincrement"`
+	MentorID      int64
+	Number        *int64
+	Pattern       string `bun:",nullzero"`
 }
 
 type Student struct {
@@ -18,26 +39,88 @@ type Student struct {
 	MmstID        string `bun:",unique"`
 	Tag           string `bun:",pk"`
 	RealName      *string
-	Labs          []*Lab     `bun:"rel:has-many,join:id=student_id"`
-	DoneLabs      []*DoneLab `bun:"rel:has-many,join:id=student_id"`
+	MentorID      *int64
+	Submissions   Submissions `bun:"rel:has-many,join:id=student_id"`
 }
 
-type Lab struct {
-	bun.BaseModel `bun:"table:labs"`
+// Submission is a lab sent for review, see lifecycle.go for its states
+type Submission struct {
+	bun.BaseModel `bun:"table:submissions"`
 	ID            int64 `bun:",pk,autoincrement"`
 	Url           string
 	StudentID     int64
 	MentorID      int64
 	Number        int64
+	State         string `bun:",nullzero,notnull,default:'submitted'"`
+	// SubmittedAt is when the submission was first sent, QueuedAt is when it
+	// last took its place in the queue, sent anew or back after a withdrawal.
+	// Labs waiting for review go by QueuedAt.
+	SubmittedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	QueuedAt    time.Time `bun:",nullzero"`
+	UpdatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+	// FinishedAt is when the submission was approved, if it was
+	FinishedAt time.Time `bun:",nullzero"`
+	// PostID is the bot's post about the submission in its mentor's DM
+	PostID string `bun:",nullzero"`
+	// Course is the course of the URL pattern the submission matched, empty
+	// for the default one
+	Course string `bun:",notnull"`
+	// What the forge said about the pull request when it was submitted, empty
+	// when it wasn't asked
+	PullTitle    string `bun:",nullzero"`
+	PullAuthor   string `bun:",nullzero"`
+	PullState    string `bun:",nullzero"`
+	HeadSHA      string `bun:",nullzero"`
+	ChangedFiles int64  `bun:",nullzero"`
+	// CIState is what the CI of the head commit said, empty when it wasn't
+	// asked or there's no CI
+	CIState string `bun:",nullzero"`
+	// Lateness is how late the submission was at first, see deadlines.go
+	Lateness string `bun:",nullzero"`
+	// Score is what the mentor gave for the approved submission, nil if it
+	// isn't scored. Revisions counts the times they asked for changes.
+	Score     *int64
+	Revisions int64
 }
 
-type DoneLab struct {
-	bun.BaseModel `bun:"table:done_labs"`
-	ID            int64 `bun:",pk,autoincrement"`
-	Url           string
-	StudentID     int64
-	MentorID      int64
+// Deadline is when the lab of the course is due. Submissions after SoftAt
+// are late, after HardAt, if set, they're overdue.
+type Deadline struct {
+	bun.BaseModel `bun:"table:deadlines"`
+	ID            int64  `bun:",pk,autoincrement"`
+	Course        string `bun:",notnull"`
 	Number        int64
+	SoftAt        time.Time
+	HardAt        time.Time `bun:",nullzero"`
+}
+
+// Transition is a single change of a submission's state. Actor is the
+// Mattermost ID of whoever made it, empty when the bot did it on its own.
+type Transition struct {
+	bun.BaseModel `bun:"table:transitions"`
+	ID            int64 `bun:",pk,autoincrement"`
+	SubmissionID  int64
+	FromState     string
+	ToState       string
+	Actor         string
+	Comment       string
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// ForgeAccount links a Mattermost user to their account on a forge. Code is
+// what they have to put on their profile to prove it's theirs, Verified is
+// set once they did, or an admin vouched for them. AnyAuthor lets their
+// submissions through whoever opened the pull request.
+type ForgeAccount struct {
+	bun.BaseModel `bun:"table:forge_accounts"`
+	ID            int64 `bun:",pk,autoincrement"`
+	MmstID        string
+	Forge         string
+	Login         string
+	Code          string `bun:",nullzero"`
+	Verified      bool
+	AnyAuthor     bool
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
 }
 
 type Admin struct {
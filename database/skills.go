@@ -0,0 +1,58 @@
+package database
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/zinstack625/mostful_manager/forge"
+)
+
+// Matches reports whether the lab is of the kind the skill covers
+func (s *Skill) Matches(lab *Submission) bool {
+	if s.Number != nil {
+		return *s.Number == lab.Number
+	}
+	repo := repoName(lab.Url)
+	if s.Pattern == "" || repo == "" {
+		return false
+	}
+	// canonical URLs are lowercased, and so the repository name
+	ok, _ := path.Match(strings.ToLower(s.Pattern), repo)
+	return ok
+}
+
+func (s *Skill) String() string {
+	if s.Number != nil {
+		return fmt.Sprintf("lab %d", *s.Number)
+	}
+	return s.Pattern
+}
+
+// qualified leaves the mentors with a skill for the lab, or everyone if
+// nobody has one
+func qualified(mentors []Mentor, lab *Submission) []Mentor {
+	var res []Mentor
+	for _, m := range mentors {
+		for _, s := range m.Skills {
+			if s.Matches(lab) {
+				res = append(res, m)
+				break
+			}
+		}
+	}
+	if len(res) == 0 {
+		return mentors
+	}
+	return res
+}
+
+// repoName takes the repository name out of a canonical pull request URL
+// like https://github.com/owner/repo/pull/1
+func repoName(lab string) string {
+	pr, err := forge.ParseCanonical(lab)
+	if err != nil {
+		return ""
+	}
+	return pr.Repo
+}
@@ -0,0 +1,29 @@
+package database
+
+import "testing"
+
+func TestSkillMatches(t *testing.T) {
+	three := int64(3)
+	tests := []struct {
+		skill Skill
+		url   string
+		want  bool
+	}{
+		{Skill{Number: &three}, "https://github.com/o/anything/pull/1", true},
+		{Skill{Pattern: "*-lab-03-*"}, "https://github.com/o/01-lab-03-name/pull/1", true},
+		{Skill{Pattern: "*-lab-03-*"}, "https://github.com/o/01-lab-04-name/pull/1", false},
+		{Skill{Pattern: "*-Lab-03-*"}, "https://github.com/o/01-lab-03-name/pull/1", true},
+		{Skill{Pattern: "*-lab-03-*"}, "https://gitlab.com/group/sub/01-lab-03-name/-/merge_requests/1", true},
+		{Skill{Pattern: "sub"}, "https://gitlab.com/group/sub/01-lab-03-name/-/merge_requests/1", false},
+		{Skill{Pattern: "*-lab-03-*"}, "https://git.example.edu/course/01-lab-03-name/pulls/2", true},
+		{Skill{Pattern: "*-lab-03-*"}, "https://bitbucket.org/o/01-lab-03-name/pull-requests/4", true},
+		{Skill{Pattern: "*"}, "not a url", false},
+		{Skill{}, "https://github.com/o/01-lab-03-name/pull/1", false},
+	}
+	for _, tt := range tests {
+		lab := &Submission{Url: tt.url, Number: 3}
+		if got := tt.skill.Matches(lab); got != tt.want {
+			t.Errorf("%s on %s: got %v, want %v", tt.skill.String(), tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/migrate"
+	_ "modernc.org/sqlite"
+)
+
+// Store is everything the bot keeps between restarts
+type Store interface {
+	GetMentorById(ctx context.Context, key int64) (*Mentor, error)
+	GetMentorByTag(ctx context.Context, key string) (*Mentor, error)
+	AddMentor(ctx context.Context, ment *Mentor) error
+	RemoveMentor(ctx context.Context, ment *Mentor) error
+	UpdateMentor(ctx context.Context, ment *Mentor) error
+	CheckMentor(ctx context.Context, ment *Mentor) (bool, error)
+	GetMentors(ctx context.Context) ([]Mentor, error)
+	SetMentorCapacity(ctx context.Context, ment *Mentor, capacity int64) error
+	SetMentorAway(ctx context.Context, ment *Mentor, from, until time.Time, handoff bool) error
+	AddSkill(ctx context.Context, skill *Skill) error
+	RemoveSkill(ctx context.Context, skill *Skill) error
+	ClearSkills(ctx context.Context, ment *Mentor) error
+
+	GetStudents(ctx context.Context) ([]Student, error)
+	GetStudentById(ctx context.Context, key int64) (*Student, error)
+	GetStudentByTag(ctx context.Context, key string) (*Student, error)
+	AddStudent(ctx context.Context, stud *Student) error
+	UpdateStudent(ctx context.Context, stud *Student) error
+	SetStudentMentor(ctx context.Context, stud *Student, ment *Mentor) error
+
+	GetForgeAccount(ctx context.Context, mmstID, forge string) (*ForgeAccount, error)
+	SetForgeAccount(ctx context.Context, acc *ForgeAccount) error
+	RemoveForgeAccount(ctx context.Context, acc *ForgeAccount) error
+
+	GetDeadlines(ctx context.Context) ([]Deadline, error)
+	GetDeadline(ctx context.Context, course string, number int64) (*Deadline, error)
+	SetDeadline(ctx context.Context, dl *Deadline) error
+	RemoveDeadline(ctx context.Context, dl *Deadline) error
+
+	AddLab(ctx context.Context, lab *Submission) (Mentor, error)
+	GetSubmission(ctx context.Context, key int64) (*Submission, error)
+	GetSubmissions(ctx context.Context, sub *Submission) ([]Submission, error)
+	GetSubmissionsByUrl(ctx context.Context, url string) ([]Submission, error)
+	GetSubmissionsByNumber(ctx context.Context, sub *Submission) ([]Submission, error)
+	SetLabPost(ctx context.Context, lab *Submission, postID string) error
+	SetPullInfo(ctx context.Context, lab *Submission) error
+	SetLateness(ctx context.Context, lab *Submission) error
+	ReassignLab(ctx context.Context, lab *Submission, actor string) (Mentor, error)
+	AssignQueued(ctx context.Context) ([]Submission, error)
+	QueuePosition(ctx context.Context, lab *Submission) (int, error)
+	Transition(ctx context.Context, sub *Submission, to, actor, comment string) error
+	FinishLab(ctx context.Context, lab *Submission, actor string) error
+	GradeLab(ctx context.Context, lab *Submission, actor string, score int64) error
+	UnfinishLab(ctx context.Context, lab *Submission, actor string) error
+	StartReview(ctx context.Context, lab *Submission, actor string) error
+	RequestChanges(ctx context.Context, lab *Submission, actor, comment string) error
+	ResubmitLab(ctx context.Context, lab *Submission, actor string) error
+	WithdrawLab(ctx context.Context, lab *Submission, actor, comment string) error
+	GetParkedLabs(ctx context.Context, headSHA string) ([]Submission, error)
+	ParkLab(ctx context.Context, lab *Submission, actor string) error
+	PassCI(ctx context.Context, lab *Submission) error
+	PushLab(ctx context.Context, lab *Submission, headSHA string) error
+	MoveLab(ctx context.Context, lab *Submission, url, actor string) error
+	GetHistory(ctx context.Context, sub *Submission) ([]Transition, error)
+
+	CheckLoad(ctx context.Context) ([]LoadDrift, error)
+	RecalcLoad(ctx context.Context) ([]LoadDrift, error)
+
+	CheckAdmin(ctx context.Context, adm *Admin) (bool, error)
+	CheckConnection(ctx context.Context) error
+
+	Migrate(ctx context.Context) error
+	Rollback(ctx context.Context) error
+	MigrationStatus(ctx context.Context) (migrate.MigrationSlice, error)
+}
+
+// Open connects to the database without touching the schema, the backend
+// is picked by the scheme of conn:
+//   - postgres://user@host:port/dbname - PostgreSQL
+//   - sqlite:///path/to/file.db or sqlite://file.db - SQLite
+//   - sqlite://:memory: - SQLite kept in memory, gone with the process
+//
+// Labs finished within loadWindow still count towards their mentor's load,
+// zero means only open labs do.
+func Open(conn string, strategy AssignmentStrategy, loadWindow time.Duration) (Store, error) {
+	d := &_db{
+		strategy:   strategy,
+		loadWindow: loadWindow,
+	}
+	// not url.Parse, it takes :memory: for a bad port
+	scheme, dsn, _ := strings.Cut(conn, "://")
+	var err error
+	switch scheme {
+	case "postgres", "postgresql":
+		d.sqldb = sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(conn)))
+		d.db = bun.NewDB(d.sqldb, pgdialect.New())
+	case "sqlite":
+		d.sqldb, err = sql.Open("sqlite", dsn)
+		if err != nil {
+			return nil, err
+		}
+		// SQLite takes one writer at a time anyway, and every connection
+		// to :memory: would get a database of its own
+		d.sqldb.SetMaxOpenConns(1)
+		d.db = bun.NewDB(d.sqldb, sqlitedialect.New())
+	default:
+		return nil, fmt.Errorf("unsupported database %q, expected postgres://, sqlite:// or sqlite://:memory:", scheme)
+	}
+	return d, nil
+}
+
+// Init opens the database and blocks until every migration is applied
+func Init(ctx context.Context, conn string, strategy AssignmentStrategy, loadWindow time.Duration) (Store, error) {
+	store, err := Open(conn, strategy, loadWindow)
+	if err != nil {
+		return nil, err
+	}
+	return store, store.Migrate(ctx)
+}
@@ -11,6 +11,12 @@ die() {
 [ -z "$PRIVATE_CHANNEL_ID" ] && die "Set PRIVATE_CHANNEL_ID envvar"
 [ -z "$DEBUG_CHANNEL_ID" ] && die "Set DEBUG_CHANNEL_ID envvar"
 
+ASSIGNMENT_STRATEGY="${ASSIGNMENT_STRATEGY:=least_loaded}"
+LOAD_DECAY_WINDOW="${LOAD_DECAY_WINDOW:=0s}"
+LOAD_CHECK_INTERVAL="${LOAD_CHECK_INTERVAL:=1h}"
+REQUIRE_LINKED_ACCOUNT="${REQUIRE_LINKED_ACCOUNT:=false}"
+APPROVE_ON_MERGE="${APPROVE_ON_MERGE:=false}"
+
 sed -i \
   -e "s/MENTOR_ADD_TOKEN/$MENTOR_ADD_TOKEN/g" \
   -e "s/MENTOR_REMOVE_TOKEN/$MENTOR_REMOVE_TOKEN/g" \
@@ -18,6 +24,26 @@ sed -i \
   -e "s/MENTOR_LABS_TOKEN/$MENTOR_LABS_TOKEN/g" \
   -e "s/LABS_TOKEN/$LABS_TOKEN/g" \
   -e "s/SET_NAME_TOKEN/$SET_NAME_TOKEN/g" \
+  -e "s/PAIRING_TOKEN/$PAIRING_TOKEN/g" \
+  -e "s/RECALC_LOAD_TOKEN/$RECALC_LOAD_TOKEN/g" \
+  -e "s/REASSIGN_TOKEN/$REASSIGN_TOKEN/g" \
+  -e "s/AWAY_TOKEN/$AWAY_TOKEN/g" \
+  -e "s/CAPACITY_TOKEN/$CAPACITY_TOKEN/g" \
+  -e "s/SKILLS_TOKEN/$SKILLS_TOKEN/g" \
+  -e "s/LINK_GITHUB_TOKEN/$LINK_GITHUB_TOKEN/g" \
+  -e "s/DEADLINE_TOKEN/$DEADLINE_TOKEN/g" \
+  -e "s/WITHDRAW_TOKEN/$WITHDRAW_TOKEN/g" \
+  -e "s/RESUBMIT_TOKEN/$RESUBMIT_TOKEN/g" \
+  -e "s/QUEUE_TOKEN/$QUEUE_TOKEN/g" \
+  -e "s/ASSIGNMENT_STRATEGY/$ASSIGNMENT_STRATEGY/g" \
+  -e "s/LOAD_DECAY_WINDOW/$LOAD_DECAY_WINDOW/g" \
+  -e "s/LOAD_CHECK_INTERVAL/$LOAD_CHECK_INTERVAL/g" \
+  -e "s|GITHUB_API_URL|$GITHUB_API_URL|g" \
+  -e "s/GITHUB_API_TOKEN/$GITHUB_API_TOKEN/g" \
+  -e "s/REQUIRE_LINKED_ACCOUNT/$REQUIRE_LINKED_ACCOUNT/g" \
+  -e "s|GITHUB_WEBHOOK_SECRET|$GITHUB_WEBHOOK_SECRET|g" \
+  -e "s|GITLAB_WEBHOOK_TOKEN|$GITLAB_WEBHOOK_TOKEN|g" \
+  -e "s/APPROVE_ON_MERGE/$APPROVE_ON_MERGE/g" \
   /etc/mostful-manager/config.json
 
 MMST_UID="${MMST_UID:=cbeer_lab}"
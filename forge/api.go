@@ -0,0 +1,276 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrNoPullRequest = errors.New("pull request does not exist")
+	ErrNoAccount     = errors.New("account does not exist")
+)
+
+// PullInfo is what the forge knows about a pull request
+type PullInfo struct {
+	Title        string
+	Author       string
+	State        string
+	Merged       bool
+	HeadSHA      string
+	ChangedFiles int64
+}
+
+// Open reports whether the pull request can still be reviewed
+func (p *PullInfo) Open() bool {
+	return p.State == "open" && !p.Merged
+}
+
+// CIState is what the checks of a commit say altogether
+type CIState string
+
+const (
+	// CINone is a commit nothing checks
+	CINone    CIState = ""
+	CIPending CIState = "pending"
+	CISuccess CIState = "success"
+	CIFailure CIState = "failure"
+)
+
+// combineCI is a failure if anything failed, pending if anything is still
+// running, and a success if anything passed
+func combineCI(states ...CIState) CIState {
+	res := CINone
+	for _, s := range states {
+		switch s {
+		case CIFailure:
+			return CIFailure
+		case CIPending:
+			res = CIPending
+		case CISuccess:
+			if res == CINone {
+				res = CISuccess
+			}
+		}
+	}
+	return res
+}
+
+// API asks a forge about pull requests and its users
+type API interface {
+	PullRequest(ctx context.Context, pr *PullRequest) (*PullInfo, error)
+	// ProfileHas reports whether the user put text into their profile, or
+	// into one of their public gists or snippets
+	ProfileHas(ctx context.Context, login, text string) (bool, error)
+	// CIStatus is what the statuses and check runs of the commit in the
+	// pull request's repository say
+	CIStatus(ctx context.Context, pr *PullRequest, sha string) (CIState, error)
+}
+
+// GitHubAPI talks to the GitHub REST API at BaseURL, https://api.github.com
+// for github.com or https://host/api/v3 for GitHub Enterprise. Token may be
+// empty for public repositories.
+type GitHubAPI struct {
+	BaseURL string
+	Token   string
+	Client  *http.Client
+}
+
+func NewGitHub(baseURL, token string) *GitHubAPI {
+	return &GitHubAPI{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Token:   token,
+		Client:  http.DefaultClient,
+	}
+}
+
+// get decodes the JSON at path into v, missing ones turn into notFound
+func (g *GitHubAPI) get(ctx context.Context, path string, notFound error, v interface{}) error {
+	_, err := g.fetch(ctx, g.BaseURL+path, notFound, v)
+	return err
+}
+
+// getPages decodes every page of the list at path, one by one into a fresh
+// value from page, and hands it to each
+func (g *GitHubAPI) getPages(ctx context.Context, path string, notFound error, page func() interface{}, each func(v interface{})) error {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	next := g.BaseURL + path + sep + "per_page=100"
+	for next != "" {
+		v := page()
+		var err error
+		next, err = g.fetch(ctx, next, notFound, v)
+		if err != nil {
+			return err
+		}
+		each(v)
+	}
+	return nil
+}
+
+// fetch decodes the JSON at addr into v and returns the URL of the next
+// page, if there's one on the same API
+func (g *GitHubAPI) fetch(ctx context.Context, addr string, notFound error, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if g.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.Token)
+	}
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", notFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("github: %s %s", strings.TrimPrefix(addr, g.BaseURL), resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", err
+	}
+	next := nextPage(resp.Header.Get("Link"))
+	// the token goes wherever the link points to
+	if !strings.HasPrefix(next, g.BaseURL+"/") {
+		return "", nil
+	}
+	return next, nil
+}
+
+// nextPage finds the rel="next" URL in a Link header like
+// <https://api.github.com/user/1/gists?page=2>; rel="next", <...>; rel="last"
+func nextPage(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if !ok {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(target), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+func (g *GitHubAPI) PullRequest(ctx context.Context, pr *PullRequest) (*PullInfo, error) {
+	var pull struct {
+		Title string `json:"title"`
+		State string `json:"state"`
+		User  struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		Merged bool `json:"merged"`
+		Head   struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+		ChangedFiles int64 `json:"changed_files"`
+	}
+	err := g.get(ctx, fmt.Sprintf("/repos/%s/%s/pulls/%d", pr.Owner, pr.Repo, pr.ID), ErrNoPullRequest, &pull)
+	if err != nil {
+		return nil, err
+	}
+	return &PullInfo{
+		Title:        pull.Title,
+		Author:       pull.User.Login,
+		State:        pull.State,
+		Merged:       pull.Merged,
+		HeadSHA:      pull.Head.SHA,
+		ChangedFiles: pull.ChangedFiles,
+	}, nil
+}
+
+func (g *GitHubAPI) ProfileHas(ctx context.Context, login, text string) (bool, error) {
+	var user struct {
+		Bio string `json:"bio"`
+	}
+	err := g.get(ctx, "/users/"+url.PathEscape(login), ErrNoAccount, &user)
+	if err != nil {
+		return false, err
+	}
+	if strings.Contains(user.Bio, text) {
+		return true, nil
+	}
+	type gist struct {
+		Description string                     `json:"description"`
+		Files       map[string]json.RawMessage `json:"files"`
+	}
+	found := false
+	err = g.getPages(ctx, "/users/"+url.PathEscape(login)+"/gists", ErrNoAccount,
+		func() interface{} { return &[]gist{} },
+		func(v interface{}) {
+			for _, gist := range *v.(*[]gist) {
+				if strings.Contains(gist.Description, text) {
+					found = true
+				}
+				for name := range gist.Files {
+					if strings.Contains(name, text) {
+						found = true
+					}
+				}
+			}
+		})
+	if err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
+func (g *GitHubAPI) CIStatus(ctx context.Context, pr *PullRequest, sha string) (CIState, error) {
+	commit := fmt.Sprintf("/repos/%s/%s/commits/%s", pr.Owner, pr.Repo, url.PathEscape(sha))
+	var status struct {
+		State      string `json:"state"`
+		TotalCount int    `json:"total_count"`
+	}
+	if err := g.get(ctx, commit+"/status", ErrNoPullRequest, &status); err != nil {
+		return CINone, err
+	}
+	type checks struct {
+		CheckRuns []struct {
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+		} `json:"check_runs"`
+	}
+	var runs []CIState
+	err := g.getPages(ctx, commit+"/check-runs", ErrNoPullRequest,
+		func() interface{} { return &checks{} },
+		func(v interface{}) {
+			for _, run := range v.(*checks).CheckRuns {
+				switch {
+				case run.Status != "completed":
+					runs = append(runs, CIPending)
+				case run.Conclusion == "success" || run.Conclusion == "neutral" || run.Conclusion == "skipped":
+					runs = append(runs, CISuccess)
+				default:
+					runs = append(runs, CIFailure)
+				}
+			}
+		})
+	if err != nil {
+		return CINone, err
+	}
+	var states []CIState
+	// GitHub says pending for commits without any statuses
+	if status.TotalCount > 0 {
+		switch status.State {
+		case "success":
+			states = append(states, CISuccess)
+		case "pending":
+			states = append(states, CIPending)
+		default:
+			states = append(states, CIFailure)
+		}
+	}
+	return combineCI(append(states, runs...)...), nil
+}
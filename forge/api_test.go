@@ -0,0 +1,242 @@
+package forge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testGitHub serves the handlers at their paths, anything else isn't found
+func testGitHub(t *testing.T, token string, handlers map[string]http.HandlerFunc) *GitHubAPI {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept"); got != "application/vnd.github+json" {
+			t.Errorf("%s: Accept %q", r.URL.Path, got)
+		}
+		want := ""
+		if token != "" {
+			want = "Bearer " + token
+		}
+		if got := r.Header.Get("Authorization"); got != want {
+			t.Errorf("%s: Authorization %q, want %q", r.URL.Path, got, want)
+		}
+		handler, ok := handlers[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return NewGitHub(srv.URL+"/", token)
+}
+
+// pages serves the JSON pages of a list, linking each to the next one
+func pages(t *testing.T, pages ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("per_page"); got != "100" {
+			t.Errorf("%s: per_page %q", r.URL.Path, got)
+		}
+		page := 1
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		if page < len(pages) {
+			next := fmt.Sprintf("http://%s%s?per_page=100&page=%d", r.Host, r.URL.Path, page+1)
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next", <%s>; rel="last"`, next, next))
+		}
+		fmt.Fprint(w, pages[page-1])
+	}
+}
+
+func TestGitHubPullRequest(t *testing.T) {
+	api := testGitHub(t, "secret", map[string]http.HandlerFunc{
+		"/repos/o/r/pulls/1": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"title":"Lab 3","state":"open","user":{"login":"stud"},"merged":false,"head":{"sha":"abc"},"changed_files":4}`)
+		},
+	})
+	info, err := api.PullRequest(context.Background(), &PullRequest{Owner: "o", Repo: "r", ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PullInfo{Title: "Lab 3", Author: "stud", State: "open", HeadSHA: "abc", ChangedFiles: 4}
+	if *info != want {
+		t.Errorf("got %+v, want %+v", *info, want)
+	}
+	if _, err := api.PullRequest(context.Background(), &PullRequest{Owner: "o", Repo: "r", ID: 2}); !errors.Is(err, ErrNoPullRequest) {
+		t.Errorf("missing pull request gave %v, want ErrNoPullRequest", err)
+	}
+}
+
+func TestGitHubErrors(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError, http.StatusBadGateway} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			api := testGitHub(t, "", map[string]http.HandlerFunc{
+				"/repos/o/r/pulls/1": func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(status)
+				},
+			})
+			_, err := api.PullRequest(context.Background(), &PullRequest{Owner: "o", Repo: "r", ID: 1})
+			if err == nil || errors.Is(err, ErrNoPullRequest) || !strings.Contains(err.Error(), fmt.Sprint(status)) {
+				t.Errorf("got %v, want an error with %d", err, status)
+			}
+		})
+	}
+}
+
+func TestGitHubProfileHasPages(t *testing.T) {
+	api := testGitHub(t, "secret", map[string]http.HandlerFunc{
+		"/users/stud": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"bio":"student"}`)
+		},
+		"/users/stud/gists": pages(t,
+			`[{"description":"notes","files":{"a.txt":{}}}]`,
+			`[{"description":"more","files":{"b.txt":{}}}]`,
+			`[{"description":"","files":{"mostful-42.txt":{}}}]`,
+		),
+	})
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"student", true},
+		{"notes", true},
+		{"mostful-42", true},
+		{"mostful-43", false},
+	}
+	for _, tt := range tests {
+		got, err := api.ProfileHas(context.Background(), "stud", tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.text, got, tt.want)
+		}
+	}
+	if _, err := api.ProfileHas(context.Background(), "nobody", "x"); !errors.Is(err, ErrNoAccount) {
+		t.Errorf("missing account gave %v, want ErrNoAccount", err)
+	}
+}
+
+func TestGitHubCIStatusPages(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		pages  []string
+		want   CIState
+	}{
+		{
+			name:   "nothing",
+			status: `{"state":"pending","total_count":0}`,
+			pages:  []string{`{"check_runs":[]}`},
+			want:   CINone,
+		},
+		{
+			name:   "status only",
+			status: `{"state":"success","total_count":1}`,
+			pages:  []string{`{"check_runs":[]}`},
+			want:   CISuccess,
+		},
+		{
+			name:   "failure on the last page",
+			status: `{"state":"success","total_count":1}`,
+			pages: []string{
+				`{"check_runs":[{"status":"completed","conclusion":"success"}]}`,
+				`{"check_runs":[{"status":"completed","conclusion":"skipped"}]}`,
+				`{"check_runs":[{"status":"completed","conclusion":"failure"}]}`,
+			},
+			want: CIFailure,
+		},
+		{
+			name:   "running on the second page",
+			status: `{"state":"pending","total_count":0}`,
+			pages: []string{
+				`{"check_runs":[{"status":"completed","conclusion":"neutral"}]}`,
+				`{"check_runs":[{"status":"in_progress"}]}`,
+			},
+			want: CIPending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := testGitHub(t, "", map[string]http.HandlerFunc{
+				"/repos/o/r/commits/abc/status": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, tt.status)
+				},
+				"/repos/o/r/commits/abc/check-runs": pages(t, tt.pages...),
+			})
+			got, err := api.CIStatus(context.Background(), &PullRequest{Owner: "o", Repo: "r"}, "abc")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubPages(t *testing.T) {
+	api := testGitHub(t, "secret", map[string]http.HandlerFunc{
+		"/list": pages(t, `[1,2]`, `[3]`, `[4,5]`),
+	})
+	var got []int
+	err := api.getPages(context.Background(), "/list", ErrNoPullRequest,
+		func() interface{} { return &[]int{} },
+		func(v interface{}) { got = append(got, *v.(*[]int)...) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[1 2 3 4 5]" {
+		t.Errorf("got %v, want every page", got)
+	}
+	err = api.getPages(context.Background(), "/missing", ErrNoPullRequest,
+		func() interface{} { return &[]int{} },
+		func(v interface{}) {})
+	if !errors.Is(err, ErrNoPullRequest) {
+		t.Errorf("missing list gave %v, want ErrNoPullRequest", err)
+	}
+}
+
+func TestGitHubStaysOnItsHost(t *testing.T) {
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("followed a link to another host with %q", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `[2]`)
+	}))
+	defer elsewhere.Close()
+	api := testGitHub(t, "secret", map[string]http.HandlerFunc{
+		"/list": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/list?page=2>; rel="next"`, elsewhere.URL))
+			fmt.Fprint(w, `[1]`)
+		},
+	})
+	var got []int
+	err := api.getPages(context.Background(), "/list", ErrNoPullRequest,
+		func() interface{} { return &[]int{} },
+		func(v interface{}) { got = append(got, *v.(*[]int)...) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[1]" {
+		t.Errorf("got %v, want the first page only", got)
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"", ""},
+		{`<https://api.github.com/x?page=2>; rel="next", <https://api.github.com/x?page=5>; rel="last"`, "https://api.github.com/x?page=2"},
+		{`<https://api.github.com/x?page=1>; rel="prev", <https://api.github.com/x?page=3>; rel="next"`, "https://api.github.com/x?page=3"},
+		{`<https://api.github.com/x?page=1>; rel="first", <https://api.github.com/x?page=1>; rel="prev"`, ""},
+	}
+	for _, tt := range tests {
+		if got := nextPage(tt.link); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
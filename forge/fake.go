@@ -0,0 +1,96 @@
+package forge
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Fake is an in-process forge API, pull requests have to be added with
+// AddPullRequest
+type Fake struct {
+	mu       sync.Mutex
+	pulls    map[string]*PullInfo
+	profiles map[string]string
+	ci       map[string]CIState
+	ciDown   error
+	down     error
+}
+
+func NewFake() *Fake {
+	return &Fake{pulls: map[string]*PullInfo{}, profiles: map[string]string{}, ci: map[string]CIState{}}
+}
+
+// SetDown makes every call fail with err, nil brings the forge back
+func (f *Fake) SetDown(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = err
+}
+
+// SetCIDown makes CIStatus alone fail with err, nil brings it back
+func (f *Fake) SetCIDown(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ciDown = err
+}
+
+// SetProfile makes the user exist with text in their profile
+func (f *Fake) SetProfile(login, text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.profiles[login] = text
+}
+
+func (f *Fake) ProfileHas(ctx context.Context, login, text string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down != nil {
+		return false, f.down
+	}
+	profile, ok := f.profiles[login]
+	if !ok {
+		return false, ErrNoAccount
+	}
+	return strings.Contains(profile, text), nil
+}
+
+// AddPullRequest makes the pull request at the canonical URL exist
+func (f *Fake) AddPullRequest(url string, info *PullInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pulls[url] = info
+}
+
+func (f *Fake) PullRequest(ctx context.Context, pr *PullRequest) (*PullInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down != nil {
+		return nil, f.down
+	}
+	info, ok := f.pulls[pr.Canonical()]
+	if !ok {
+		return nil, ErrNoPullRequest
+	}
+	copied := *info
+	return &copied, nil
+}
+
+// SetCI makes the commit's checks say state
+func (f *Fake) SetCI(sha string, state CIState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ci[sha] = state
+}
+
+func (f *Fake) CIStatus(ctx context.Context, pr *PullRequest, sha string) (CIState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down != nil {
+		return CINone, f.down
+	}
+	if f.ciDown != nil {
+		return CINone, f.ciDown
+	}
+	return f.ci[sha], nil
+}
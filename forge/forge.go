@@ -0,0 +1,159 @@
+// Package forge understands the pull request URLs of the code hosting sites
+// students send their labs from.
+package forge
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kind is the software a host runs, it decides what its URLs look like
+type Kind string
+
+const (
+	GitHub    Kind = "github"
+	GitLab    Kind = "gitlab"
+	Gitea     Kind = "gitea"
+	Bitbucket Kind = "bitbucket"
+)
+
+// pullPath is the part of a pull request URL between the repository and the
+// pull request number
+var pullPath = map[Kind]string{
+	GitHub:    "pull",
+	GitLab:    "-/merge_requests",
+	Gitea:     "pulls",
+	Bitbucket: "pull-requests",
+}
+
+var publicHosts = map[string]Kind{
+	"github.com":    GitHub,
+	"gitlab.com":    GitLab,
+	"gitea.com":     Gitea,
+	"codeberg.org":  Gitea,
+	"bitbucket.org": Bitbucket,
+}
+
+var ErrNotPullRequest = errors.New("not a pull request URL")
+
+// PullRequest is a pull request, or a merge request as GitLab calls it.
+// Owner may have slashes in it for GitLab subgroups.
+type PullRequest struct {
+	Kind  Kind
+	Host  string
+	Owner string
+	Repo  string
+	ID    int64
+}
+
+// Forges knows which hosts run which forge
+type Forges struct {
+	hosts map[string]Kind
+}
+
+// New adds the self-hosted forges, host name to kind, to the public ones
+func New(hosts map[string]string) (*Forges, error) {
+	f := &Forges{hosts: map[string]Kind{}}
+	for host, kind := range publicHosts {
+		f.hosts[host] = kind
+	}
+	for host, kind := range hosts {
+		if _, ok := pullPath[Kind(kind)]; !ok {
+			return nil, fmt.Errorf("unknown forge %q for %s", kind, host)
+		}
+		f.hosts[strings.ToLower(host)] = Kind(kind)
+	}
+	return f, nil
+}
+
+// Parse makes sense of a pull request URL. Anything after the pull request
+// number, like /files, a trailing slash or a query, is ignored, and the case
+// of the owner and repository too.
+func (f *Forges) Parse(raw string) (*PullRequest, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, ErrNotPullRequest
+	}
+	host := strings.ToLower(u.Host)
+	kind, ok := f.hosts[host]
+	if !ok {
+		// www.github.com and the like lead to the same place
+		host = strings.TrimPrefix(host, "www.")
+		kind, ok = f.hosts[host]
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown host %s", ErrNotPullRequest, host)
+	}
+	pull := "/" + pullPath[kind] + "/"
+	repo, rest, ok := strings.Cut(u.Path, pull)
+	if !ok {
+		return nil, ErrNotPullRequest
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	number, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || number < 1 {
+		return nil, ErrNotPullRequest
+	}
+	slash := strings.LastIndex(repo, "/")
+	if slash <= 0 {
+		return nil, ErrNotPullRequest
+	}
+	owner := strings.Trim(repo[:slash], "/")
+	// only GitLab nests groups
+	if owner == "" || (kind != GitLab && strings.Contains(owner, "/")) {
+		return nil, ErrNotPullRequest
+	}
+	// forges don't tell Owner/Repo from owner/repo
+	return &PullRequest{
+		Kind:  kind,
+		Host:  host,
+		Owner: strings.ToLower(owner),
+		Repo:  strings.ToLower(repo[slash+1:]),
+		ID:    number,
+	}, nil
+}
+
+// ParseCanonical makes sense of a URL Canonical made. The forge is told by
+// the path rather than the host, so self-hosted ones needn't be known.
+func ParseCanonical(raw string) (*PullRequest, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	// GitLab first, its subgroups may be called anything
+	for _, kind := range []Kind{GitLab, Bitbucket, Gitea, GitHub} {
+		if !strings.Contains(u.Path, "/"+pullPath[kind]+"/") {
+			continue
+		}
+		f := &Forges{hosts: map[string]Kind{strings.ToLower(u.Host): kind}}
+		if pr, err := f.Parse(raw); err == nil {
+			return pr, nil
+		}
+	}
+	return nil, ErrNotPullRequest
+}
+
+// Canonical is the one URL of the pull request, however it was written
+func (pr *PullRequest) Canonical() string {
+	return fmt.Sprintf("https://%s/%s/%s/%s/%d", pr.Host, pr.Owner, pr.Repo, pullPath[pr.Kind], pr.ID)
+}
+
+var labNumber = regexp.MustCompile(`(?i)lab[-_]?([0-9]+)`)
+
+// LabNumber guesses the lab number from the repository name, like 3 for
+// 01-lab-03-name or lab3-name, zero if there's none
+func (pr *PullRequest) LabNumber() int64 {
+	m := labNumber.FindStringSubmatch(pr.Repo)
+	if m == nil {
+		return 0
+	}
+	number, _ := strconv.ParseInt(m[1], 10, 64)
+	return number
+}
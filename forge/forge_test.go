@@ -0,0 +1,166 @@
+package forge
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	forges, err := New(map[string]string{"git.example.edu": "gitlab", "Gitea.Example.Edu": "gitea"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url       string
+		want      PullRequest
+		canonical string
+	}{
+		{
+			url:       "https://github.com/owner/01-lab-03-name/pull/7",
+			want:      PullRequest{Kind: GitHub, Host: "github.com", Owner: "owner", Repo: "01-lab-03-name", ID: 7},
+			canonical: "https://github.com/owner/01-lab-03-name/pull/7",
+		},
+		{
+			url:       "  http://WWW.GitHub.com/owner/repo/pull/7/files?diff=split#top ",
+			want:      PullRequest{Kind: GitHub, Host: "github.com", Owner: "owner", Repo: "repo", ID: 7},
+			canonical: "https://github.com/owner/repo/pull/7",
+		},
+		{
+			url:       "https://github.com/Owner/01-Lab-03-Name/pull/7",
+			want:      PullRequest{Kind: GitHub, Host: "github.com", Owner: "owner", Repo: "01-lab-03-name", ID: 7},
+			canonical: "https://github.com/owner/01-lab-03-name/pull/7",
+		},
+		{
+			url:       "https://github.com/owner/repo/pull/7/",
+			want:      PullRequest{Kind: GitHub, Host: "github.com", Owner: "owner", Repo: "repo", ID: 7},
+			canonical: "https://github.com/owner/repo/pull/7",
+		},
+		{
+			url:       "https://gitlab.com/Group/Sub/group/repo/-/merge_requests/12/diffs",
+			want:      PullRequest{Kind: GitLab, Host: "gitlab.com", Owner: "group/sub/group", Repo: "repo", ID: 12},
+			canonical: "https://gitlab.com/group/sub/group/repo/-/merge_requests/12",
+		},
+		{
+			url:       "https://git.example.edu/course/repo/-/merge_requests/1",
+			want:      PullRequest{Kind: GitLab, Host: "git.example.edu", Owner: "course", Repo: "repo", ID: 1},
+			canonical: "https://git.example.edu/course/repo/-/merge_requests/1",
+		},
+		{
+			url:       "https://gitea.example.edu/owner/repo/pulls/2",
+			want:      PullRequest{Kind: Gitea, Host: "gitea.example.edu", Owner: "owner", Repo: "repo", ID: 2},
+			canonical: "https://gitea.example.edu/owner/repo/pulls/2",
+		},
+		{
+			url:       "https://codeberg.org/owner/repo/pulls/3",
+			want:      PullRequest{Kind: Gitea, Host: "codeberg.org", Owner: "owner", Repo: "repo", ID: 3},
+			canonical: "https://codeberg.org/owner/repo/pulls/3",
+		},
+		{
+			url:       "https://bitbucket.org/owner/repo/pull-requests/4/overview",
+			want:      PullRequest{Kind: Bitbucket, Host: "bitbucket.org", Owner: "owner", Repo: "repo", ID: 4},
+			canonical: "https://bitbucket.org/owner/repo/pull-requests/4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			pr, err := forges.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *pr != tt.want {
+				t.Errorf("got %+v, want %+v", *pr, tt.want)
+			}
+			if got := pr.Canonical(); got != tt.canonical {
+				t.Errorf("canonical %s, want %s", got, tt.canonical)
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	forges, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{
+		"",
+		"github.com/owner/repo/pull/1",
+		"ftp://github.com/owner/repo/pull/1",
+		"https://example.com/owner/repo/pull/1",
+		"https://github.com/owner/repo",
+		"https://github.com/owner/repo/issues/1",
+		"https://github.com/owner/repo/pull/",
+		"https://github.com/owner/repo/pull/0",
+		"https://github.com/owner/repo/pull/-1",
+		"https://github.com/owner/repo/pull/one",
+		"https://github.com/repo/pull/1",
+		"https://github.com/group/owner/repo/pull/1",
+		"https://gitlab.com/owner/repo/merge_requests/1",
+	} {
+		t.Run(url, func(t *testing.T) {
+			if pr, err := forges.Parse(url); err == nil {
+				t.Errorf("got %+v, want an error", *pr)
+			}
+		})
+	}
+	if _, err := forges.Parse("https://example.com/owner/repo/pull/1"); !errors.Is(err, ErrNotPullRequest) {
+		t.Errorf("unknown host gave %v, want ErrNotPullRequest", err)
+	}
+}
+
+func TestParseCanonical(t *testing.T) {
+	tests := []struct {
+		url  string
+		want PullRequest
+	}{
+		{"https://github.com/o/repo/pull/1", PullRequest{Kind: GitHub, Host: "github.com", Owner: "o", Repo: "repo", ID: 1}},
+		{"https://gitlab.com/group/sub/repo/-/merge_requests/2", PullRequest{Kind: GitLab, Host: "gitlab.com", Owner: "group/sub", Repo: "repo", ID: 2}},
+		{"https://gitlab.com/pull/7/repo/-/merge_requests/2", PullRequest{Kind: GitLab, Host: "gitlab.com", Owner: "pull/7", Repo: "repo", ID: 2}},
+		{"https://git.example.edu/o/pull/pulls/3", PullRequest{Kind: Gitea, Host: "git.example.edu", Owner: "o", Repo: "pull", ID: 3}},
+		{"https://bb.example.edu/o/repo/pull-requests/4", PullRequest{Kind: Bitbucket, Host: "bb.example.edu", Owner: "o", Repo: "repo", ID: 4}},
+	}
+	for _, tt := range tests {
+		pr, err := ParseCanonical(tt.url)
+		if err != nil {
+			t.Errorf("%s: %s", tt.url, err)
+			continue
+		}
+		if *pr != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.url, *pr, tt.want)
+		}
+		if got := pr.Canonical(); got != tt.url {
+			t.Errorf("%s: canonical %s", tt.url, got)
+		}
+	}
+	for _, url := range []string{"", "https://github.com/o/repo", "https://github.com/o/repo/issues/1"} {
+		if _, err := ParseCanonical(url); err == nil {
+			t.Errorf("%q parsed", url)
+		}
+	}
+}
+
+func TestNewRejectsUnknownKind(t *testing.T) {
+	if _, err := New(map[string]string{"git.example.edu": "sourcehut"}); err == nil {
+		t.Error("unknown forge kind accepted")
+	}
+}
+
+func TestLabNumber(t *testing.T) {
+	tests := []struct {
+		repo string
+		want int64
+	}{
+		{"01-lab-03-name", 3},
+		{"lab3-name", 3},
+		{"Lab_12", 12},
+		{"LAB-007-name", 7},
+		{"homework-2", 0},
+		{"labs", 0},
+	}
+	for _, tt := range tests {
+		pr := PullRequest{Repo: tt.repo}
+		if got := pr.LabNumber(); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.repo, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package forge
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// EventKind is what happened to a pull request
+type EventKind string
+
+const (
+	// PullPushed is new commits in the pull request
+	PullPushed EventKind = "pushed"
+	// PullClosed is the pull request closed without merging
+	PullClosed EventKind = "closed"
+	PullMerged EventKind = "merged"
+	// CIChanged is a check of the HeadSHA commit done or changed, the event
+	// has no URL
+	CIChanged EventKind = "ci"
+)
+
+// ErrIgnoredEvent is a webhook event nothing has to be done about
+var ErrIgnoredEvent = errors.New("event is of no interest")
+
+// Event is a webhook event about a pull request. URL is the pull request's
+// web URL, Actor is the forge login of whoever caused it.
+type Event struct {
+	Kind    EventKind
+	URL     string
+	HeadSHA string
+	Actor   string
+}
+
+// VerifyGitHub checks the X-Hub-Signature-256 header, an HMAC of the body
+// keyed with the webhook secret
+func VerifyGitHub(secret string, body []byte, signature string) bool {
+	sum, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || secret == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sum, mac.Sum(nil))
+}
+
+// ParseGitHubEvent makes sense of a GitHub webhook, event is the
+// X-GitHub-Event header
+func ParseGitHubEvent(event string, body []byte) (*Event, error) {
+	switch event {
+	case "status", "check_run", "check_suite":
+		return parseGitHubCI(event, body)
+	case "pull_request":
+	default:
+		return nil, ErrIgnoredEvent
+	}
+	var payload struct {
+		Action      string `json:"action"`
+		PullRequest struct {
+			HTMLURL string `json:"html_url"`
+			Merged  bool   `json:"merged"`
+			Head    struct {
+				SHA string `json:"sha"`
+			} `json:"head"`
+		} `json:"pull_request"`
+		Sender struct {
+			Login string `json:"login"`
+		} `json:"sender"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	ev := &Event{
+		URL:     payload.PullRequest.HTMLURL,
+		HeadSHA: payload.PullRequest.Head.SHA,
+		Actor:   payload.Sender.Login,
+	}
+	switch {
+	case payload.Action == "synchronize":
+		ev.Kind = PullPushed
+	case payload.Action == "closed" && payload.PullRequest.Merged:
+		ev.Kind = PullMerged
+	case payload.Action == "closed":
+		ev.Kind = PullClosed
+	default:
+		return nil, ErrIgnoredEvent
+	}
+	return ev, nil
+}
+
+// parseGitHubCI takes the commit out of a status or check event
+func parseGitHubCI(event string, body []byte) (*Event, error) {
+	var payload struct {
+		Action   string `json:"action"`
+		SHA      string `json:"sha"`
+		CheckRun struct {
+			HeadSHA string `json:"head_sha"`
+		} `json:"check_run"`
+		CheckSuite struct {
+			HeadSHA string `json:"head_sha"`
+		} `json:"check_suite"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	ev := &Event{Kind: CIChanged}
+	switch event {
+	case "status":
+		ev.HeadSHA = payload.SHA
+	case "check_run":
+		ev.HeadSHA = payload.CheckRun.HeadSHA
+	case "check_suite":
+		ev.HeadSHA = payload.CheckSuite.HeadSHA
+	}
+	if (event != "status" && payload.Action != "completed") || ev.HeadSHA == "" {
+		return nil, ErrIgnoredEvent
+	}
+	return ev, nil
+}
+
+// VerifyGitLab checks the X-Gitlab-Token header against the webhook's
+// secret token
+func VerifyGitLab(secret, token string) bool {
+	return secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(token)) == 1
+}
+
+// ParseGitLabEvent makes sense of a GitLab merge request webhook
+func ParseGitLabEvent(body []byte) (*Event, error) {
+	var payload struct {
+		ObjectKind string `json:"object_kind"`
+		User       struct {
+			Username string `json:"username"`
+		} `json:"user"`
+		ObjectAttributes struct {
+			Action     string `json:"action"`
+			URL        string `json:"url"`
+			OldRev     string `json:"oldrev"`
+			LastCommit struct {
+				ID string `json:"id"`
+			} `json:"last_commit"`
+		} `json:"object_attributes"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.ObjectKind != "merge_request" {
+		return nil, ErrIgnoredEvent
+	}
+	attrs := payload.ObjectAttributes
+	ev := &Event{
+		URL:     attrs.URL,
+		HeadSHA: attrs.LastCommit.ID,
+		Actor:   payload.User.Username,
+	}
+	switch {
+	// updates without oldrev are edits of the title or description
+	case attrs.Action == "update" && attrs.OldRev != "":
+		ev.Kind = PullPushed
+	case attrs.Action == "merge":
+		ev.Kind = PullMerged
+	case attrs.Action == "close":
+		ev.Kind = PullClosed
+	default:
+		return nil, ErrIgnoredEvent
+	}
+	return ev, nil
+}
@@ -0,0 +1,138 @@
+package forge
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyGitHub(t *testing.T) {
+	const body = `{"action":"closed"}`
+	tests := []struct {
+		name      string
+		secret    string
+		body      string
+		signature string
+		want      bool
+	}{
+		{"signed", "s3cret", body, sign("s3cret", body), true},
+		{"other secret", "s3cret", body, sign("other", body), false},
+		{"other body", "s3cret", body + " ", sign("s3cret", body), false},
+		{"without prefix", "s3cret", body, sign("s3cret", body)[len("sha256="):], true},
+		{"sha1", "s3cret", body, "sha1=" + sign("s3cret", body)[len("sha256="):], false},
+		{"not hex", "s3cret", body, "sha256=zz", false},
+		{"truncated", "s3cret", body, sign("s3cret", body)[:20], false},
+		{"unsigned", "s3cret", body, "", false},
+		{"no secret", "", body, sign("", body), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyGitHub(tt.secret, []byte(tt.body), tt.signature); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyGitLab(t *testing.T) {
+	tests := []struct {
+		secret, token string
+		want          bool
+	}{
+		{"s3cret", "s3cret", true},
+		{"s3cret", "s3cre", false},
+		{"s3cret", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := VerifyGitLab(tt.secret, tt.token); got != tt.want {
+			t.Errorf("%q with %q: got %v, want %v", tt.secret, tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestParseGitHubEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		event string
+		body  string
+		want  *Event
+	}{
+		{"pushed", "pull_request", `{"action":"synchronize","pull_request":{"html_url":"https://github.com/o/r/pull/1","head":{"sha":"abc"}},"sender":{"login":"stud"}}`,
+			&Event{Kind: PullPushed, URL: "https://github.com/o/r/pull/1", HeadSHA: "abc", Actor: "stud"}},
+		{"merged", "pull_request", `{"action":"closed","pull_request":{"html_url":"https://github.com/o/r/pull/1","merged":true,"head":{"sha":"abc"}},"sender":{"login":"ment"}}`,
+			&Event{Kind: PullMerged, URL: "https://github.com/o/r/pull/1", HeadSHA: "abc", Actor: "ment"}},
+		{"closed", "pull_request", `{"action":"closed","pull_request":{"html_url":"https://github.com/o/r/pull/1","merged":false,"head":{"sha":"abc"}},"sender":{"login":"stud"}}`,
+			&Event{Kind: PullClosed, URL: "https://github.com/o/r/pull/1", HeadSHA: "abc", Actor: "stud"}},
+		{"opened", "pull_request", `{"action":"opened"}`, nil},
+		{"status", "status", `{"sha":"abc","state":"success"}`, &Event{Kind: CIChanged, HeadSHA: "abc"}},
+		{"check run done", "check_run", `{"action":"completed","check_run":{"head_sha":"abc"}}`, &Event{Kind: CIChanged, HeadSHA: "abc"}},
+		{"check run started", "check_run", `{"action":"created","check_run":{"head_sha":"abc"}}`, nil},
+		{"check suite done", "check_suite", `{"action":"completed","check_suite":{"head_sha":"abc"}}`, &Event{Kind: CIChanged, HeadSHA: "abc"}},
+		{"status without commit", "status", `{"state":"success"}`, nil},
+		{"push", "push", `{}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGitHubEvent(tt.event, []byte(tt.body))
+			if tt.want == nil {
+				if !errors.Is(err, ErrIgnoredEvent) {
+					t.Errorf("got %+v, %v, want it ignored", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != *tt.want {
+				t.Errorf("got %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+	if _, err := ParseGitHubEvent("pull_request", []byte("{")); err == nil || errors.Is(err, ErrIgnoredEvent) {
+		t.Errorf("broken body gave %v, want a decoding error", err)
+	}
+}
+
+func TestParseGitLabEvent(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want *Event
+	}{
+		{"pushed", `{"object_kind":"merge_request","user":{"username":"stud"},"object_attributes":{"action":"update","url":"https://gitlab.com/g/r/-/merge_requests/1","oldrev":"aaa","last_commit":{"id":"bbb"}}}`,
+			&Event{Kind: PullPushed, URL: "https://gitlab.com/g/r/-/merge_requests/1", HeadSHA: "bbb", Actor: "stud"}},
+		{"edited", `{"object_kind":"merge_request","object_attributes":{"action":"update","url":"https://gitlab.com/g/r/-/merge_requests/1"}}`, nil},
+		{"merged", `{"object_kind":"merge_request","user":{"username":"ment"},"object_attributes":{"action":"merge","url":"https://gitlab.com/g/r/-/merge_requests/1","last_commit":{"id":"bbb"}}}`,
+			&Event{Kind: PullMerged, URL: "https://gitlab.com/g/r/-/merge_requests/1", HeadSHA: "bbb", Actor: "ment"}},
+		{"closed", `{"object_kind":"merge_request","user":{"username":"stud"},"object_attributes":{"action":"close","url":"https://gitlab.com/g/r/-/merge_requests/1"}}`,
+			&Event{Kind: PullClosed, URL: "https://gitlab.com/g/r/-/merge_requests/1", Actor: "stud"}},
+		{"opened", `{"object_kind":"merge_request","object_attributes":{"action":"open"}}`, nil},
+		{"pipeline", `{"object_kind":"pipeline"}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGitLabEvent([]byte(tt.body))
+			if tt.want == nil {
+				if !errors.Is(err, ErrIgnoredEvent) {
+					t.Errorf("got %+v, %v, want it ignored", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != *tt.want {
+				t.Errorf("got %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
@@ -1,18 +1,24 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"time"
 
 	"github.com/zinstack625/mostful_manager/bot"
 	"github.com/zinstack625/mostful_manager/config"
 	"github.com/zinstack625/mostful_manager/database"
+	"github.com/zinstack625/mostful_manager/forge"
+	"github.com/zinstack625/mostful_manager/mattermost"
 )
 
 var url = flag.String("url", "", "URL of where the Mattermost server resides")
 var ownUrl = flag.String("ownUrl", "", "URL of this bot")
 var token = flag.String("tok", "", "Bot access token")
-var dburl = flag.String("db", "", "URL to connect to database with")
+var dburl = flag.String("db", "", "URL to connect to database with, postgres://, sqlite:// or sqlite://:memory:")
 var configPath = flag.String("cfg", "config.json", "Config path in filesystem")
 var botUserID = flag.String("uid", "cbeer_lab", "Bot user tag")
 var pchanID = flag.String("pchan", "", "Private channel ID")
@@ -20,15 +26,104 @@ var dchanID = flag.String("dchan", "", "Debug channel ID")
 
 func main() {
 	flag.Parse()
+	if flag.Arg(0) == "migrate" {
+		migrateCmd(flag.Arg(1))
+		return
+	}
 	if url == nil {
 		log.Fatal("-url is a required argument")
 	}
 	if token == nil {
 		log.Fatal("-tok is a required argument")
 	}
-	config.IntegrationTokens.Init(*configPath)
-	database.DB.Init(*dburl)
-	bot := &bot.Bot{}
-	bot.Init(*url, *ownUrl, *token, *botUserID, *pchanID, *dchanID)
-	select {}
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("Unable to read config: ", err)
+	}
+	strategy, err := database.NewAssignmentStrategy(cfg.AssignmentStrategy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	loadDecay, err := cfg.LoadDecay()
+	if err != nil {
+		log.Fatal("load_decay_window: ", err)
+	}
+	loadCheck, err := cfg.LoadCheck()
+	if err != nil {
+		log.Fatal("load_check_interval: ", err)
+	}
+	forges, err := forge.New(cfg.Forges)
+	if err != nil {
+		log.Fatal("forges: ", err)
+	}
+	var pulls forge.API
+	if cfg.GitHubAPI != "" {
+		pulls = forge.NewGitHub(cfg.GitHubAPI, cfg.GitHubToken)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	store, err := database.Init(ctx, *dburl, strategy, loadDecay)
+	cancel()
+	if err != nil {
+		log.Fatal("Unable to set up database: ", err)
+	}
+	client, err := mattermost.Connect(*url, *token)
+	if err != nil {
+		log.Fatal("Unable to connect to Mattermost: ", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	user, err := client.GetUserByUsername(ctx, *botUserID)
+	cancel()
+	if err != nil {
+		log.Fatal("Unable to find the bot user: ", err)
+	}
+	b := bot.New(bot.Options{
+		Client:           client,
+		User:             user,
+		Store:            store,
+		Config:           cfg,
+		Forges:           forges,
+		Pulls:            pulls,
+		OwnUrl:           *ownUrl,
+		PrivateChannelID: *pchanID,
+		DebugChannelID:   *dchanID,
+	})
+	b.Listen()
+	if loadCheck > 0 {
+		go b.WatchLoad(loadCheck)
+	}
+	go b.WatchAway(time.Minute)
+	log.Fatal(http.ListenAndServe("0.0.0.0:5000", b.Handler()))
+}
+
+// migrateCmd handles "migrate [up|down|status]", up being the default
+func migrateCmd(action string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	store, err := database.Open(*dburl, nil, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch action {
+	case "", "up":
+		err = store.Migrate(ctx)
+	case "down":
+		err = store.Rollback(ctx)
+	case "status":
+		ms, err := store.MigrationStatus(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range ms {
+			status := "pending"
+			if m.IsApplied() {
+				status = fmt.Sprintf("applied in group #%d", m.GroupID)
+			}
+			fmt.Printf("%s %s\n", m, status)
+		}
+	default:
+		log.Fatalf("Unknown migrate action %q, expected up, down or status", action)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 }
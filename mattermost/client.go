@@ -0,0 +1,95 @@
+// Package mattermost narrows the Mattermost API down to the calls the bot
+// makes, so that it can run against a fake as well as a live server.
+package mattermost
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+type Client interface {
+	CreateDirectChannel(ctx context.Context, userId1, userId2 string) (*model.Channel, error)
+	CreatePost(ctx context.Context, post *model.Post) (*model.Post, error)
+	GetPost(ctx context.Context, postId string) (*model.Post, error)
+	UpdatePost(ctx context.Context, post *model.Post) (*model.Post, error)
+	OpenInteractiveDialog(ctx context.Context, request model.OpenDialogRequest) error
+	UploadFile(ctx context.Context, data []byte, channelId, filename string) (*model.FileUploadResponse, error)
+	GetUsersByIds(ctx context.Context, userIds []string) ([]*model.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+
+	// Listen starts receiving websocket events into Events
+	Listen()
+	Events() <-chan *model.WebSocketEvent
+	Close()
+}
+
+type live struct {
+	api *model.Client4
+	ws  *model.WebSocketClient
+}
+
+// Connect builds a client for the Mattermost instance at instanceUrl, which
+// is a bare host without a scheme
+func Connect(instanceUrl, token string) (Client, error) {
+	ws, err := model.NewWebSocketClient4(fmt.Sprintf("wss://%s", instanceUrl), token)
+	if err != nil {
+		return nil, err
+	}
+	api := model.NewAPIv4Client(fmt.Sprintf("https://%s", instanceUrl))
+	api.SetToken(token)
+	return &live{api: api, ws: ws}, nil
+}
+
+func (c *live) CreateDirectChannel(ctx context.Context, userId1, userId2 string) (*model.Channel, error) {
+	channel, _, err := c.api.CreateDirectChannel(ctx, userId1, userId2)
+	return channel, err
+}
+
+func (c *live) CreatePost(ctx context.Context, post *model.Post) (*model.Post, error) {
+	created, _, err := c.api.CreatePost(ctx, post)
+	return created, err
+}
+
+func (c *live) GetPost(ctx context.Context, postId string) (*model.Post, error) {
+	post, _, err := c.api.GetPost(ctx, postId, "")
+	return post, err
+}
+
+func (c *live) UpdatePost(ctx context.Context, post *model.Post) (*model.Post, error) {
+	updated, _, err := c.api.UpdatePost(ctx, post.Id, post)
+	return updated, err
+}
+
+func (c *live) OpenInteractiveDialog(ctx context.Context, request model.OpenDialogRequest) error {
+	_, err := c.api.OpenInteractiveDialog(ctx, request)
+	return err
+}
+
+func (c *live) UploadFile(ctx context.Context, data []byte, channelId, filename string) (*model.FileUploadResponse, error) {
+	file, _, err := c.api.UploadFile(ctx, data, channelId, filename)
+	return file, err
+}
+
+func (c *live) GetUsersByIds(ctx context.Context, userIds []string) ([]*model.User, error) {
+	users, _, err := c.api.GetUsersByIds(ctx, userIds)
+	return users, err
+}
+
+func (c *live) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	user, _, err := c.api.GetUserByUsername(ctx, username, "")
+	return user, err
+}
+
+func (c *live) Listen() {
+	c.ws.Listen()
+}
+
+func (c *live) Events() <-chan *model.WebSocketEvent {
+	return c.ws.EventChannel
+}
+
+func (c *live) Close() {
+	c.ws.Close()
+}
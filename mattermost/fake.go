@@ -0,0 +1,203 @@
+package mattermost
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// Fake is an in-process Mattermost that remembers every channel, post and
+// file the bot creates. Users have to be added with AddUser, events are fed
+// to the bot with Send.
+type Fake struct {
+	mu       sync.Mutex
+	users    map[string]*model.User
+	channels map[string]*model.Channel
+	posts    map[string]*model.Post
+	files    map[string][]byte
+	dialogs  []model.OpenDialogRequest
+	order    []string
+	events   chan *model.WebSocketEvent
+	closed   sync.Once
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		users:    map[string]*model.User{},
+		channels: map[string]*model.Channel{},
+		posts:    map[string]*model.Post{},
+		files:    map[string][]byte{},
+		events:   make(chan *model.WebSocketEvent, 16),
+	}
+}
+
+func (f *Fake) AddUser(user *model.User) *model.User {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if user.Id == "" {
+		user.Id = model.NewId()
+	}
+	f.users[user.Id] = user
+	return user
+}
+
+func (f *Fake) CreateDirectChannel(ctx context.Context, userId1, userId2 string) (*model.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.users[userId1] == nil || f.users[userId2] == nil {
+		return nil, ErrNotFound
+	}
+	name := model.GetDMNameFromIds(userId1, userId2)
+	for _, channel := range f.channels {
+		if channel.Name == name {
+			return channel, nil
+		}
+	}
+	channel := &model.Channel{
+		Id:   model.NewId(),
+		Name: name,
+		Type: model.ChannelTypeDirect,
+	}
+	f.channels[channel.Id] = channel
+	return channel, nil
+}
+
+func (f *Fake) CreatePost(ctx context.Context, post *model.Post) (*model.Post, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	created := post.Clone()
+	created.Id = model.NewId()
+	created.CreateAt = model.GetMillis()
+	f.posts[created.Id] = created
+	f.order = append(f.order, created.Id)
+	return created.Clone(), nil
+}
+
+func (f *Fake) GetPost(ctx context.Context, postId string) (*model.Post, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	post, ok := f.posts[postId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return post.Clone(), nil
+}
+
+func (f *Fake) UpdatePost(ctx context.Context, post *model.Post) (*model.Post, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	old, ok := f.posts[post.Id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	updated := post.Clone()
+	updated.ChannelId = old.ChannelId
+	updated.CreateAt = old.CreateAt
+	updated.EditAt = model.GetMillis()
+	f.posts[post.Id] = updated
+	return updated.Clone(), nil
+}
+
+func (f *Fake) OpenInteractiveDialog(ctx context.Context, request model.OpenDialogRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dialogs = append(f.dialogs, request)
+	return nil
+}
+
+func (f *Fake) UploadFile(ctx context.Context, data []byte, channelId, filename string) (*model.FileUploadResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info := &model.FileInfo{
+		Id:        model.NewId(),
+		ChannelId: channelId,
+		Name:      filename,
+		Size:      int64(len(data)),
+	}
+	f.files[info.Id] = data
+	return &model.FileUploadResponse{FileInfos: []*model.FileInfo{info}}, nil
+}
+
+func (f *Fake) GetUsersByIds(ctx context.Context, userIds []string) ([]*model.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var users []*model.User
+	for _, id := range userIds {
+		if user, ok := f.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (f *Fake) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, user := range f.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (f *Fake) Listen() {}
+
+func (f *Fake) Events() <-chan *model.WebSocketEvent {
+	return f.events
+}
+
+// Close stops the events, it's fine to call it more than once
+func (f *Fake) Close() {
+	f.closed.Do(func() { close(f.events) })
+}
+
+// Send delivers an event to whoever listens to Events
+func (f *Fake) Send(event *model.WebSocketEvent) {
+	f.events <- event
+}
+
+// Posts returns every post in the order they were created
+func (f *Fake) Posts() []*model.Post {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	posts := make([]*model.Post, len(f.order))
+	for i, id := range f.order {
+		posts[i] = f.posts[id].Clone()
+	}
+	return posts
+}
+
+// DMs returns the posts in the direct channel of the two users, oldest first
+func (f *Fake) DMs(userId1, userId2 string) []*model.Post {
+	name := model.GetDMNameFromIds(userId1, userId2)
+	var dms []*model.Post
+	for _, post := range f.Posts() {
+		f.mu.Lock()
+		channel := f.channels[post.ChannelId]
+		f.mu.Unlock()
+		if channel != nil && channel.Name == name {
+			dms = append(dms, post)
+		}
+	}
+	return dms
+}
+
+// Dialogs returns every dialog opened so far
+func (f *Fake) Dialogs() []model.OpenDialogRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]model.OpenDialogRequest(nil), f.dialogs...)
+}
+
+// File returns the contents of an uploaded file
+func (f *Fake) File(fileId string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.files[fileId]
+	return data, ok
+}
@@ -0,0 +1,12 @@
+package mattermost
+
+import "testing"
+
+func TestFakeCloseTwice(t *testing.T) {
+	f := NewFake()
+	f.Close()
+	f.Close()
+	if _, ok := <-f.Events(); ok {
+		t.Error("events still open after Close")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/zinstack625/mostful_manager/mattermost"
 )
 
 func GetMyIP() (net.Addr, error) {
@@ -17,20 +18,27 @@ func GetMyIP() (net.Addr, error) {
 }
 
 func RespondEphemeral(resp http.ResponseWriter, text string) {
+	RespondEphemeralAttachments(resp, text, nil)
+}
+
+// RespondEphemeralAttachments answers a slash command with text and
+// attachments, buttons for one, only the user sees
+func RespondEphemeralAttachments(resp http.ResponseWriter, text string, attachments []*model.SlackAttachment) {
 	post := model.OutgoingWebhookResponse{
 		Text:         &text,
+		Attachments:  attachments,
 		ResponseType: "ephemeral",
 	}
 	postjson, _ := json.Marshal(post)
 	resp.Write(postjson)
 }
 
-func SendDM(bot_id string, user_id string, msg string, attachments []*model.SlackAttachment, client *model.Client4) error {
+func SendDM(bot_id string, user_id string, msg string, attachments []*model.SlackAttachment, client mattermost.Client) (*model.Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
 	defer cancel()
-	dm, _, err := client.CreateDirectChannel(ctx, bot_id, user_id)
+	dm, err := client.CreateDirectChannel(ctx, bot_id, user_id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	postdmstud := model.Post{
 		ChannelId: dm.Id,
@@ -39,6 +47,5 @@ func SendDM(bot_id string, user_id string, msg string, attachments []*model.Slac
 	if attachments != nil {
 		postdmstud.AddProp("attachments", attachments)
 	}
-	_, _, err = client.CreatePost(ctx, &postdmstud)
-	return err
+	return client.CreatePost(ctx, &postdmstud)
 }